@@ -0,0 +1,29 @@
+package litter
+
+import "reflect"
+
+// dumpAtomic dumps the types of sync/atomic with a Load method, like atomic.Int64 and
+// atomic.Pointer[T], as the value they hold, like atomic.Int64(42), rather than as their internals.
+func dumpAtomic(s *dumpState, value reflect.Value) bool {
+	// Atomics in unexported fields are loaded through their address if possible.
+	v := exposeUnexported(deInterface(value))
+	t := v.Type()
+	if t.Kind() != reflect.Struct || t.PkgPath() != "sync/atomic" || !v.CanInterface() {
+		return false
+	}
+	load, ok := reflect.PtrTo(t).MethodByName("Load")
+	if !ok || load.Type.NumIn() != 1 || load.Type.NumOut() != 1 {
+		return false
+	}
+	ptr := reflect.New(t)
+	if v.CanAddr() {
+		ptr = v.Addr()
+	} else {
+		ptr.Elem().Set(v)
+	}
+	s.dumpType(v)
+	s.writeString("(")
+	s.dumpVal(ptr.MethodByName("Load").Call(nil)[0])
+	s.writeString(")")
+	return true
+}
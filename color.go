@@ -0,0 +1,102 @@
+package litter
+
+import (
+	"bytes"
+	"strings"
+)
+
+// The ANSI escape codes used by Color.
+const (
+	colorReset   = "\x1b[0m"
+	colorString  = "\x1b[32m"
+	colorNumber  = "\x1b[36m"
+	colorKeyword = "\x1b[35m"
+	colorComment = "\x1b[90m"
+)
+
+// colorize colors the strings, numbers, nil and booleans, and comments of a dump in the Go format.
+func colorize(dump []byte) []byte {
+	out := new(bytes.Buffer)
+	for i := 0; i < len(dump); {
+		start := i
+		color := ""
+		switch c := dump[i]; {
+		case c == '"':
+			i = stringEnd(dump, i)
+			color = colorString
+		case c == '/' && i+1 < len(dump) && dump[i+1] == '/':
+			i = commentEnd(dump, i, "\n")
+			color = colorComment
+		case c == '/' && i+1 < len(dump) && dump[i+1] == '*':
+			i = commentEnd(dump, i, "*/")
+			color = colorComment
+		case isIdentByte(c) && !isDigit(c):
+			for i < len(dump) && isIdentByte(dump[i]) {
+				i++
+			}
+			switch string(dump[start:i]) {
+			case "nil", "true", "false":
+				color = colorKeyword
+			}
+		case isDigit(c):
+			i = numberEnd(dump, i)
+			color = colorNumber
+		default:
+			i++
+		}
+		if color == "" {
+			out.Write(dump[start:i])
+			continue
+		}
+		out.WriteString(color)
+		out.Write(dump[start:i])
+		out.WriteString(colorReset)
+	}
+	return out.Bytes()
+}
+
+// stringEnd returns the index after the quoted string starting at i.
+func stringEnd(dump []byte, i int) int {
+	for i++; i < len(dump); i++ {
+		switch dump[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return len(dump)
+}
+
+// commentEnd returns the index after the comment starting at i, which ends with end. Line comments
+// end before the newline.
+func commentEnd(dump []byte, i int, end string) int {
+	n := bytes.Index(dump[i+2:], []byte(end))
+	switch {
+	case n < 0:
+		return len(dump)
+	case end == "\n":
+		return i + 2 + n
+	}
+	return i + 2 + n + len(end)
+}
+
+// numberEnd returns the index after the number starting at i, including exponents like e+06.
+func numberEnd(dump []byte, i int) int {
+	for i++; i < len(dump); i++ {
+		c := dump[i]
+		exponent := (c == '+' || c == '-') && strings.IndexByte("eEpP", dump[i-1]) >= 0
+		if !isIdentByte(c) && c != '.' && !exponent {
+			break
+		}
+	}
+	return i
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || isDigit(c) || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c >= 0x80
+}
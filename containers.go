@@ -0,0 +1,76 @@
+package litter
+
+import (
+	"container/list"
+	"container/ring"
+	"reflect"
+)
+
+var (
+	listType = reflect.TypeOf(list.List{})
+	ringType = reflect.TypeOf(ring.Ring{})
+)
+
+// containerElements returns the values held by a list.List or a ring.Ring, in order, without
+// modifying them. The elements are returned as addressable interface values.
+func containerElements(v reflect.Value) ([]reflect.Value, bool) {
+	if !v.CanInterface() {
+		return nil, false
+	}
+	var elements []reflect.Value
+	switch v.Type() {
+	case listType:
+		// Copies of a list can be iterated, since its elements refer to the original list.
+		l := v.Interface().(list.List)
+		for e := l.Front(); e != nil; e = e.Next() {
+			elements = append(elements, reflect.ValueOf(&e.Value).Elem())
+		}
+	case ringType:
+		if v.FieldByName("next").IsNil() {
+			// An uninitialized ring, which the methods of Ring would initialize.
+			return []reflect.Value{v.FieldByName("Value")}, true
+		}
+		r := v.Interface().(ring.Ring)
+		// The element before the ring's successor is the ring itself rather than the copy.
+		start := r.Next().Prev()
+		elements = append(elements, reflect.ValueOf(&start.Value).Elem())
+		for p := start.Next(); p != start; p = p.Next() {
+			elements = append(elements, reflect.ValueOf(&p.Value).Elem())
+		}
+	default:
+		return nil, false
+	}
+	return elements, true
+}
+
+// dumpContainer dumps a list.List or a ring.Ring as the values it holds, like a slice, rather than
+// as its linked internals.
+func dumpContainer(s *dumpState, value reflect.Value) bool {
+	v := deInterface(value)
+	elements, ok := containerElements(v)
+	if !ok {
+		return false
+	}
+	s.dumpType(v)
+	if len(elements) == 0 {
+		s.writeString("{}")
+		return true
+	}
+	s.writeString("{")
+	s.newlineWithPointerNameComment()
+	s.depth++
+	for i, element := range elements {
+		s.indent()
+		s.pushIndex(i)
+		s.dumpVal(element)
+		s.popPath()
+		if !s.config.Compact || i < len(elements)-1 {
+			s.writeString(",")
+		}
+		s.newlineWithPointerNameComment()
+	}
+	s.depth--
+	s.indent()
+	s.writeString("}")
+	return true
+}
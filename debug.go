@@ -0,0 +1,30 @@
+package litter
+
+import "os"
+
+// debugCompactThreshold is the CompactThreshold used by Debug on terminals.
+const debugCompactThreshold = 80
+
+// Debug dumps values to stdout like Dump, with settings suited to where the output goes. When
+// stdout is a terminal, the dump is colored, unless the NO_COLOR environment variable is set, and
+// values whose compact form is at most 80 bytes long are collapsed onto a single line, unless Config
+// sets its own CompactThreshold. When stdout is redirected to a file or pipe, the values are dumped
+// as with Dump. Config itself is not modified.
+func Debug(values ...interface{}) {
+	o := Config
+	if isTerminal(os.Stdout) {
+		if o.CompactThreshold == 0 {
+			o.CompactThreshold = debugCompactThreshold
+		}
+		if os.Getenv("NO_COLOR") == "" {
+			o.Color = true
+		}
+	}
+	o.Dump(values...)
+}
+
+// isTerminal returns true if the file is a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -2,20 +2,37 @@ package litter
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"go/format"
 	"io"
+	"math"
 	"os"
+	"path/filepath"
 	"reflect"
 	"regexp"
 	"runtime"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 )
 
 var (
+	dumperType            = reflect.TypeOf((*Dumper)(nil)).Elem()
+	indentedDumperType    = reflect.TypeOf((*IndentedDumper)(nil)).Elem()
+	conditionalDumperType = reflect.TypeOf((*ConditionalDumper)(nil)).Elem()
+	formatterType         = reflect.TypeOf((*fmt.Formatter)(nil)).Elem()
+
 	packageNameStripperRegexp = regexp.MustCompile(`\b[a-zA-Z_]+[a-zA-Z_0-9]+\.`)
 	compactTypeRegexp         = regexp.MustCompile(`\s*([,;{}()])\s*`)
+	anonymousFuncRegexp       = regexp.MustCompile(`\.func\d+(\.\d+)*$`)
+	identifierRegexp          = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+	// homePackageRegexps caches the compiled regexps stripping home packages, keyed by package name.
+	homePackageRegexps sync.Map
 )
 
 // Dumper is the interface for implementing custom dumper for your types.
@@ -23,6 +40,77 @@ type Dumper interface {
 	LitterDump(w io.Writer)
 }
 
+// IndentedDumper is the interface for custom dumpers that indent their output themselves, such as
+// ones dumping nested values with litter. The depth is the indentation level of the line the output
+// starts on, each level being two spaces, and the output is written as is, without re-indenting.
+type IndentedDumper interface {
+	LitterDumpIndented(w io.Writer, depth int)
+}
+
+// ConditionalDumper is the interface for custom dumpers that only apply to some values, such as
+// ones leaving zero values to the default rendering. Values for which LitterShouldDump returns false
+// are dumped as if their type had no custom dumper.
+type ConditionalDumper interface {
+	LitterShouldDump() bool
+}
+
+// isCustomDumper returns true if values of the type dump themselves.
+func isCustomDumper(t reflect.Type) bool {
+	return t.Implements(dumperType) || t.Implements(indentedDumperType)
+}
+
+// usesCustomDumper returns true if the value is dumped by its custom dumper, which requires calling
+// its methods.
+func usesCustomDumper(v reflect.Value) bool {
+	return v.CanInterface() && v.Kind() != reflect.Interface && isCustomDumper(v.Type()) &&
+		(!v.Type().Implements(conditionalDumperType) || shouldDumpCustom(v))
+}
+
+// shouldDumpCustom returns the result of LitterShouldDump for a value implementing
+// ConditionalDumper, if it can be called.
+func shouldDumpCustom(v reflect.Value) bool {
+	if !v.CanInterface() || isPointerValue(v) && v.IsNil() {
+		return true
+	}
+	return v.Interface().(ConditionalDumper).LitterShouldDump()
+}
+
+// KeyOrderer is the interface for map types that want their entries dumped in a specific order,
+// such as insertion order. Keys returned by LitterKeys that are not present in the map, or not
+// assignable to its key type, are ignored, and map keys that are not returned are dumped after the ordered ones, in the usual sorted order.
+type KeyOrderer interface {
+	LitterKeys() []interface{}
+}
+
+// Format selects the syntax values are dumped in.
+type Format int
+
+const (
+	// FormatGo dumps values as Go literals. This is the default.
+	FormatGo Format = iota
+
+	// FormatYAML dumps values in an indentation-based layout resembling YAML, without type names.
+	// Reused pointers are written as YAML anchors and aliases.
+	FormatYAML
+
+	// FormatRepr dumps values on a single line in the style of Python's repr, with None, True and
+	// False, lists, dicts, and structs as constructor calls like BasicStruct(Public=1). Pointers are
+	// followed transparently, and cyclic references are written as [...], {...} or Type(...), as
+	// Python does.
+	FormatRepr
+
+	// FormatLogfmt dumps structs as key=value pairs on a single line, as used by logfmt, like
+	// user.id=1 user.name="bob". Nested structs and maps with string keys are flattened into dotted
+	// keys, with field names lowercased and map keys as they are, and other values are written as
+	// their quoted compact dump.
+	FormatLogfmt
+
+	// FormatFlatPaths dumps values as one assignment per line for each scalar they contain, like
+	// .User.Addresses[0].City = "NYC", so that line based diffs pinpoint what changed. Circular
+	// references are written as <circular reference to .Path>.
+	FormatFlatPaths
+)
+
 // Options represents configuration options for litter
 type Options struct {
 	Compact           bool
@@ -40,6 +128,380 @@ type Options struct {
 	// when it's safe. This is useful for diffing two structures, where pointer variables would cause
 	// false changes. However, circular graphs are still detected and elided to avoid infinite output.
 	DisablePointerReplacement bool
+
+	// ShowPrivateFor lists struct types whose private fields are dumped even when HidePrivateFields is
+	// set. This allows inspecting your own types without the noise of third-party internals.
+	ShowPrivateFor []reflect.Type
+
+	// HideFuncFields, if true, hides struct fields holding functions, such as callbacks and hooks.
+	HideFuncFields bool
+
+	// InternStrings, if true, replaces strings occurring more than once with labels like s0, similar
+	// to reused pointers. Only strings at least MinInternLength bytes long are replaced.
+	InternStrings   bool
+	MinInternLength int
+
+	// EnumNames maps integer types to the names of their values. Values of these types are dumped
+	// as conversions followed by their name as a comment, like Status(2) /* Active */. This is useful
+	// for enums that lack a String method.
+	EnumNames map[reflect.Type]map[int64]string
+
+	// ShowFuncLocation, if true, appends the source file and line where a dumped function is defined
+	// as a comment. This helps telling anonymous functions apart.
+	ShowFuncLocation bool
+
+	// PointerLabelPrefix is the prefix of the labels used to refer to reused pointers. The labels
+	// are numbered from 0, and the prefix defaults to "p", giving labels like p0.
+	PointerLabelPrefix string
+
+	// CompactThreshold, if positive, collapses structs, slices and maps onto a single line, as with
+	// Compact, when their compact form is at most this many bytes long. Larger values are expanded
+	// as usual.
+	CompactThreshold int
+
+	// Format selects the syntax values are dumped in. Defaults to FormatGo.
+	Format Format
+
+	// PathExclusions lists fields to hide by their full path from the dumped value. Paths are field
+	// names separated by dots, with slice and array indices and map keys in brackets, such as
+	// `User.Credentials.Password`, `Users[0].Password` or `Settings["db"].Password`.
+	PathExclusions []string
+
+	// MaxDepth, if positive, limits how deeply nested structs, slices and maps are dumped. Values
+	// nested deeper are elided as {...}.
+	MaxDepth int
+
+	// DisableDefaultDumpers, if true, disables the built-in rendering of well-known types, dumping
+	// them like any other value. DumpFunc can also be used to override individual types.
+	DisableDefaultDumpers bool
+
+	// DumpBufferContents, if true, dumps a *bytes.Buffer as its unread content, which is read
+	// without consuming it, and other values held by io.Reader interfaces by their type only.
+	DumpBufferContents bool
+
+	// ShowFieldLayout, if true, annotates each dumped struct field with its byte offset and size,
+	// which is useful when optimizing the memory layout of structs.
+	ShowFieldLayout bool
+
+	// HideTopLevelType, if true, omits the type name of the dumped value itself, while keeping the
+	// type names of nested values.
+	HideTopLevelType bool
+
+	// SortMapsByValue, if true, dumps map entries ordered by their values, largest first, instead of
+	// by their keys. Entries with equal values are ordered by key. This is handy for maps of counts.
+	SortMapsByValue bool
+
+	// SummarizeAbove, if positive, dumps slices, arrays and maps with more than this many elements
+	// as their type and length only, like []User(len=5000), instead of expanding their elements.
+	SummarizeAbove int
+
+	// InlineScalarPointers, if true, dumps pointers to booleans, numbers and strings as the value
+	// they point to, without the & prefix, which is handy for structs full of optional fields. It has
+	// no effect with StrictGo.
+	InlineScalarPointers bool
+
+	// MapKeyDumpFunc, like DumpFunc, can take over dumping map keys, such as to dump enum keys by
+	// name. It returns false to dump the key as usual, in which case DumpFunc still applies.
+	MapKeyDumpFunc func(reflect.Value, io.Writer) bool
+
+	// MaskStrings, if true, dumps strings as their length only, like string(len=11), showing the
+	// shape of the data without its content, in every format. Strings in individual struct fields can
+	// be masked with the `litter:"mask"` field tag instead.
+	MaskStrings bool
+
+	// PositionalStructs, if true, dumps structs as positional literals without field names, like
+	// Point{1, 2}, when all their fields are dumped. Structs with any fields hidden are dumped with
+	// field names as usual.
+	PositionalStructs bool
+
+	// MaxRecursionDepth limits how deeply the dump recurses into nested values, including pointers,
+	// so that pathologically deep structures, such as very long linked lists, are elided with
+	// <max recursion exceeded> instead of overflowing the stack. Defaults to 10000.
+	MaxRecursionDepth int
+
+	// GroupDigits, if true, follows integers of more than three digits with a comment showing them
+	// with thousands separators, like 1000000 /* 1,000,000 */, to make large numbers easier to read.
+	GroupDigits bool
+
+	// CanonicalLabels, if true, orders map entries whose keys dump identically, such as distinct
+	// pointers to equal values, by their values, so that structurally equal graphs get the same
+	// pointer labels and dump identically, regardless of map iteration order. This makes diffing
+	// dumps of separately built graphs reliable.
+	CanonicalLabels bool
+
+	// UintptrNames, if set, resolves uintptr values, such as handles used with cgo and syscalls, to
+	// human readable names, which are written as a comment after the value, like
+	// Handle(0x1234) /* window "main" */. It returns false for values without a name.
+	UintptrNames func(uintptr) (string, bool)
+
+	// ShowScalarTypes, if true, dumps values of named boolean, numeric and string types as
+	// conversions, like Temperature(98.6), instead of bare literals.
+	ShowScalarTypes bool
+
+	// TabularSlices, if true, dumps slices and arrays of structs with only boolean, numeric and
+	// string fields as a table, with one row per element and the fields aligned in columns:
+	//
+	//	[]Person{
+	//	  {Name: "Bob",   Age: 20},
+	//	  {Name: "Alice", Age: 7},
+	//	}
+	TabularSlices bool
+
+	// AbbreviateTypes, if true, replaces type names used more than once with short aliases like T0,
+	// which are listed in a legend comment before the dumped value. The value is dumped twice, first
+	// to count the uses of each type name, so custom dumpers are called twice.
+	AbbreviateTypes bool
+
+	// ProtoText, if true, dumps messages generated by protoc-gen-go without their generated
+	// internals, showing their text format in a comment, and generated enums with their names, like
+	// pb.Status(1) /* ACTIVE */. With StrictGo, messages are dumped as structs instead.
+	ProtoText bool
+
+	// TypedNils, if true, dumps nil pointers with their type, like (*int)(nil), instead of a bare
+	// nil. Combined with StrictGo, this makes fixtures with nil pointers in interfaces compile to
+	// values of the original type.
+	TypedNils bool
+
+	// Transform, if set, is called with each value before it is dumped, and can return a
+	// replacement value to dump instead, and true. This allows normalizing volatile values, such as
+	// times, for deterministic snapshots. Replacement values are not transformed again.
+	Transform func(reflect.Value) (reflect.Value, bool)
+
+	// Gofmt, if true, formats the dumped Go literals with gofmt, indenting with tabs and aligning
+	// fields and comments. Dumps that gofmt cannot parse, such as values elided by MaxDepth, are
+	// written as they are.
+	Gofmt bool
+
+	// AnnotateKinds, if true, follows each dumped value with a comment showing its reflect.Kind,
+	// like /* kind=ptr */. This helps troubleshooting surprising output, and reporting bugs.
+	AnnotateKinds bool
+
+	// InlineScalarStructs, if true, dumps structs whose dumped fields all hold booleans, numbers or
+	// strings on a single line, like Point{X: 1, Y: 2}, while other structs are expanded as usual.
+	InlineScalarStructs bool
+
+	// DumpContextFunc, like DumpFunc, can take over dumping values, but also receives the context
+	// of the value in the dumped tree, allowing for example to summarize deeply nested values. It is
+	// consulted after DumpFunc.
+	DumpContextFunc func(DumpContext, reflect.Value, io.Writer) bool
+
+	// FlagNames maps integer types used as bit flags to the names of their bits. Values of these
+	// types are dumped as conversions of their flags OR'ed together, like Perm(Read|Write), followed
+	// by the numeric value of any bits without a name.
+	FlagNames map[reflect.Type]map[uint64]string
+
+	// ShowMethods, if true, lists the exported methods of dumped structs, including those with
+	// pointer receivers, as comments after their fields, like // Sum() int. Combined with
+	// HidePrivateFields, this shows the public API of types.
+	ShowMethods bool
+
+	// HideEmptyStrings, if true, hides struct fields holding empty strings, while keeping other
+	// zero values, unlike HideZeroValues.
+	HideEmptyStrings bool
+
+	// ExplainElisions, if true, follows each pointer label that replaces a value with a comment
+	// telling whether the value was elided because of a circular reference or because the pointer
+	// was already dumped.
+	ExplainElisions bool
+
+	// TimeLocation, if not nil, is the location time.Time values are shown in. By default times are
+	// shown in their own location.
+	TimeLocation *time.Location
+
+	// HideKinds lists kinds of struct fields to hide, such as reflect.Func and reflect.Chan for
+	// fields holding runtime state rather than data. Interface fields are hidden by the kind of the
+	// value they hold as well.
+	HideKinds []reflect.Kind
+
+	// TagInterfaceFields, if true, tags the values of interface fields holding structs, or pointers to
+	// them, with their dynamic type in the YAML format, like Shape: !Circle, showing which variant of
+	// a sum type the field holds. The Go format always shows these types.
+	TagInterfaceFields bool
+
+	// MaxMapLength, if greater than 0, is the number of entries dumped of maps, in the order they
+	// are sorted in. The remaining entries are counted in a comment, like // ... 4500 more, or
+	// # ... 4500 more in the YAML format.
+	MaxMapLength int
+
+	// OnTruncate, if not nil, is called for each value elided or shortened by a limit, such as
+	// MaxDepth, MaxRecursionDepth, SummarizeAbove, MaxMapLength, MaxPointerDepth or MaxOutputBytes,
+	// with the name of the limit as the reason and the path of the value, like "Users[3].Friends". It
+	// is called once the value passed to Dump has been dumped, so tools can tell that the dump is
+	// incomplete.
+	OnTruncate func(reason string, path string)
+
+	// UseFormatter, if true, dumps values implementing fmt.Formatter as formatted with %+v, followed
+	// by their type in a comment.
+	UseFormatter bool
+
+	// Verbose, if true, dumps everything, like go-spew would: private fields and fields with zero
+	// values are shown, FieldExclusions, FieldInclusions, PathExclusions, HideFuncFields,
+	// HideEmptyStrings and HideKinds are ignored, well-known types are dumped with their internals as
+	// if DisableDefaultDumpers was set, pointers and repeated subtrees are not replaced as if
+	// DisablePointerReplacement was set and DeduplicateSubtrees was not, and no limits such as
+	// MaxDepth, SummarizeAbove, MaxMapLength or MaxPointerDepth apply. Circular references are still
+	// elided. Custom dumpers and formatters are used even for values held by private fields.
+	Verbose bool
+
+	// DerefMapKeys, if true, dumps map keys that are pointers as the values they point to, and orders
+	// them accordingly. Keys pointing to equal values remain separate entries, even though they look
+	// alike. Nil keys are dumped as nil.
+	DerefMapKeys bool
+
+	// FieldInclusions, if not nil, hides the struct fields whose names it does not match, at every
+	// level of the dumped value. Fields it matches are still subject to FieldExclusions and the other
+	// options hiding fields.
+	FieldInclusions *regexp.Regexp
+
+	// VarName, if not empty, makes the Go format dump each value as a variable declaration, like
+	// var expected = []int{1, 2}. The variables of the values after the first are numbered, like
+	// expected2. Together with StrictGo and Gofmt, this gives dumps that can be
+	// pasted into test files as fixtures.
+	VarName string
+
+	// DeduplicateSubtrees, if true, replaces structs, slices, arrays and maps equal to one dumped
+	// before with a reference to its path, like Node{...} /* same as Children[0] */, shrinking dumps
+	// of repetitive trees whose repetition is not due to shared pointers. This is expensive, since
+	// the values are compared by dumping them.
+	DeduplicateSubtrees bool
+
+	// MinSubtreeSize is the length in bytes of the compact dump of the smallest values replaced by
+	// DeduplicateSubtrees. Defaults to 100.
+	MinSubtreeSize int
+
+	// BareNilSlices, if true, dumps nil slices as a bare nil, as litter used to, instead of with their
+	// type, like []int(nil).
+	BareNilSlices bool
+
+	// MapsAsPairs, if true, dumps maps as slices of key and value pairs in the order the keys are
+	// sorted in, like []struct { Key string; Value int }{{Key: "a", Value: 1}}, which is valid Go
+	// with a fixed order.
+	MapsAsPairs bool
+
+	// RedactTypes lists types whose values are always dumped as <redacted>, wherever they occur and
+	// in every format, including map keys in paths, such as a type used for passwords and tokens.
+	RedactTypes []reflect.Type
+
+	// ErrorStackTraces, if true, follows errors carrying a stack trace with a comment listing its
+	// frames. Errors carry stack traces if they have a StackTrace method returning a slice of
+	// program counters, like []uintptr or the StackTrace type of github.com/pkg/errors.
+	ErrorStackTraces bool
+
+	// SchemaOnly, if true, dumps the structure of the type of values instead of their content: the
+	// names and types of the fields of structs, recursively, like User{ID int; Addr *Address{...}}.
+	// Zero values dump the same as any other.
+	SchemaOnly bool
+
+	// MaxOutputBytes, if positive, limits the number of bytes written by a call to Dump, in total for
+	// all the values passed to it, separators included. The output is cut off at the limit and
+	// followed by "...", including output of custom dumpers.
+	MaxOutputBytes int
+
+	// PointerPreamble, if true, writes the values of reused pointers before the dumped value, as
+	// definitions like p0 := &T{...}, so that the value only refers to them by their labels. This
+	// reads better than defining them wherever they first occur, as for map values sharing pointers.
+	PointerPreamble bool
+
+	// HexDumpStructs lists struct types, such as headers of binary protocols, whose values are
+	// followed by a hexdump of their memory, with offsets, bytes in hex and as ASCII. Only types
+	// made of booleans, numbers, arrays and structs have a fixed layout and are hexdumped.
+	HexDumpStructs []reflect.Type
+
+	// SortSlices, if true, dumps the elements of slices sorted, numbers by value and other elements
+	// by their dumps, so that slices used as sets dump the same regardless of their order. This
+	// changes the apparent order of the elements, which may matter elsewhere.
+	SortSlices bool
+
+	// UnquoteIdentKeys, if true, dumps string map keys that are identifiers, like name, without
+	// quotes, so that maps read like struct literals. Other keys, like "full name", stay quoted.
+	UnquoteIdentKeys bool
+
+	// TimeFormat, if not empty, changes how time.Time values are dumped: "unix" and "unixmilli" dump
+	// them as Unix timestamps in seconds or milliseconds, followed by the time in a comment, and any
+	// other value is a layout for time.Time.Format, dumping them as strings. Zero times are always
+	// dumped as time.Time{}, since their timestamps would be meaningless. Pointers to times are
+	// dumped as pointers to the int64 or string, like (func(v int64) *int64 { return &v })(1700000000).
+	TimeFormat string
+
+	// ShowSliceSharing, if true, follows slices sharing their backing array with a slice dumped
+	// before with a comment giving the path of that slice, like /* shares backing array with Items */.
+	// Slices share it if their capacities overlap, since appending to one can overwrite the other.
+	ShowSliceSharing bool
+
+	// ScalarArrayInline, if positive, dumps slices and arrays of numbers or booleans with at most
+	// this many elements on a single line, like [4]float64{1, 2, 3, 4}, even when not Compact. This
+	// keeps matrices and fixed-size buffers readable.
+	ScalarArrayInline int
+
+	// TypeNameFunc, if not nil, returns the names types are dumped with, replacing the package name
+	// options and the spacing of Compact, to alias, shorten or annotate them. AbbreviateTypes still
+	// applies to the returned names.
+	TypeNameFunc func(t reflect.Type) string
+
+	// ShowSizes, if true, follows structs, slices, arrays and maps with a comment estimating their
+	// size in memory, including the memory they reference, like /* ~4.2 KB */. Memory referenced
+	// more than once is counted once per value, and allocator overhead is ignored.
+	ShowSizes bool
+
+	// MaxPointerDepth, if positive, limits how many pointers in a row are dereferenced, as in a
+	// **int. Pointers beyond it are dumped as their label, if dumped before, or as their address,
+	// like (*int)(0xc000012345).
+	MaxPointerDepth int
+
+	// Color, if true, colors the strings, numbers, nil and booleans, and comments of the Go format
+	// with ANSI escape codes, for terminals. Debug sets it when stdout is a terminal.
+	Color bool
+}
+
+// DumpContext describes where in the dumped tree a value passed to Options.DumpContextFunc is.
+type DumpContext struct {
+	// Depth is the nesting depth of the value in structs, slices and maps, which is 0 for the
+	// dumped value itself.
+	Depth int
+
+	// Path is the path to the value from the dumped value, like `Users[0].Credentials` or
+	// `Settings["db"]`, which is empty for the dumped value itself.
+	Path string
+
+	// MapKey is true if the value is a map key, whose depth and path are those of its entry.
+	MapKey bool
+}
+
+// dumpContext returns the context of the value being dumped, for DumpContextFunc, which is the
+// given map key if it is valid.
+func (s *dumpState) dumpContext(key reflect.Value) DumpContext {
+	path := s.path
+	if key.IsValid() {
+		path = append(path[:len(path):len(path)], pathElement{key: key, masked: s.maskStrings})
+	}
+	return DumpContext{Depth: len(path), Path: s.formatPath(path), MapKey: key.IsValid()}
+}
+
+// hasDumpFunc returns true if DumpFunc or DumpContextFunc may take over dumping values.
+func (o *Options) hasDumpFunc() bool {
+	return o.DumpFunc != nil || o.DumpContextFunc != nil
+}
+
+// defaultMaxRecursionDepth is the recursion limit used when Options.MaxRecursionDepth is not set.
+const defaultMaxRecursionDepth = 10000
+
+// maxRecursionDepth returns the recursion limit, applying the default.
+func (o *Options) maxRecursionDepth() int {
+	if o.MaxRecursionDepth > 0 {
+		return o.MaxRecursionDepth
+	}
+	return defaultMaxRecursionDepth
+}
+
+// defaultMinSubtreeSize is the size used when Options.MinSubtreeSize is not set.
+const defaultMinSubtreeSize = 100
+
+// minSubtreeSize returns the size of the smallest values deduplicated, applying the default.
+func (o *Options) minSubtreeSize() int {
+	if o.MinSubtreeSize > 0 {
+		return o.MinSubtreeSize
+	}
+	return defaultMinSubtreeSize
 }
 
 // Config is the default config used when calling Dump
@@ -54,192 +516,1046 @@ type dumpState struct {
 	w                 io.Writer
 	depth             int
 	config            *Options
-	pointers          ptrmap
+	pointers          *ptrmap
+	strings           *stringmap
 	visitedPointers   ptrmap
 	parentPointers    ptrmap
 	currentPointer    *ptrinfo
 	homePackageRegexp *regexp.Regexp
+	path              []pathElement
+	skipIndent        bool
+	omitType          bool
+	maskStrings       bool
+	recursion         int
+	typeUses          map[string]int
+	typeOrder         []string
+	typeAliases       map[string]string
+	transformed       bool
+	truncations       []truncation
+	subtrees          map[string]string
+	parentPaths       map[ptrkey]string
+	skipStackTrace    int
+	output            *limitWriter
+	collectPointers   bool
+	pointerOrder      []reflect.Value
+	definingPointer   bool
+	regions           *regionRecorder
+	backingArrays     *[]backingArray
+	pointerChain      int
+	sizes             map[ptrkey]uintptr
+	mapKey            reflect.Value
+	varName           string
+}
+
+// truncation records content elided by a limit, for OnTruncate.
+type truncation struct {
+	reason string
+	path   string
+}
+
+// truncated records that content at the current path was elided by the limit named by the reason.
+func (s *dumpState) truncated(reason string) {
+	if s.config.OnTruncate != nil {
+		s.truncations = append(s.truncations, truncation{reason: reason, path: s.pathString()})
+	}
+}
+
+// reportTruncations calls OnTruncate for the content elided by limits. This is done once the value
+// is dumped, since values may be dumped more than once internally, as when trying CompactThreshold.
+func (s *dumpState) reportTruncations() {
+	for _, t := range s.truncations {
+		s.config.OnTruncate(t.reason, t.path)
+	}
+	s.truncations = nil
+}
+
+// writeError carries an error returned by the writer up to Fdump.
+type writeError struct {
+	err error
 }
 
 func (s *dumpState) write(b []byte) {
 	if _, err := s.w.Write(b); err != nil {
-		panic(err)
+		s.writeFailed(err)
 	}
 }
 
+// writeString writes the string without converting it to []byte, if the writer implements
+// io.StringWriter, as bytes.Buffer and os.File do.
 func (s *dumpState) writeString(str string) {
-	s.write([]byte(str))
+	if _, err := io.WriteString(s.w, str); err != nil {
+		s.writeFailed(err)
+	}
+}
+
+// Write writes to the output like write, so that the print helpers abort dumping when writing
+// fails too.
+func (s *dumpState) Write(b []byte) (int, error) {
+	s.write(b)
+	return len(b), nil
+}
+
+// writeFailed aborts dumping, carrying the error up to Fdump.
+func (s *dumpState) writeFailed(err error) {
+	if err == errOutputLimit {
+		s.truncated("MaxOutputBytes")
+	}
+	panic(writeError{err})
 }
 
+// indentSpaces are the spaces written by indent.
+var indentSpaces = bytes.Repeat([]byte(" "), 64)
+
 func (s *dumpState) indent() {
+	if s.skipIndent {
+		s.skipIndent = false
+		return
+	}
 	if !s.config.Compact {
-		s.write(bytes.Repeat([]byte("  "), s.depth))
+		// Written from a shared buffer of spaces, since indenting every line is on the hot path.
+		for n := 2 * s.depth; n > 0; n -= len(indentSpaces) {
+			if n < len(indentSpaces) {
+				s.write(indentSpaces[:n])
+				break
+			}
+			s.write(indentSpaces)
+		}
 	}
 }
 
+func (s *dumpState) pointerLabel(ptr *ptrinfo) string {
+	prefix := s.config.PointerLabelPrefix
+	if prefix == "" {
+		prefix = "p"
+	}
+	return ptr.label(prefix)
+}
+
 func (s *dumpState) newlineWithPointerNameComment() {
+	s.newlineWithComment("")
+}
+
+// newlineWithComment ends the line with the given comment, preceded by the pending pointer name if
+// any.
+func (s *dumpState) newlineWithComment(comment string) {
 	if ptr := s.currentPointer; ptr != nil {
-		if s.config.Compact {
-			s.write([]byte(fmt.Sprintf("/*%s*/", ptr.label())))
+		if comment != "" {
+			comment = s.pointerLabel(ptr) + " " + comment
 		} else {
-			s.write([]byte(fmt.Sprintf(" // %s\n", ptr.label())))
+			comment = s.pointerLabel(ptr)
 		}
 		s.currentPointer = nil
+	}
+	if comment != "" {
+		if s.config.Compact {
+			s.writeString(fmt.Sprintf("/*%s*/", comment))
+		} else {
+			s.writeString(fmt.Sprintf(" // %s\n", comment))
+		}
 		return
 	}
 	if !s.config.Compact {
-		s.write([]byte("\n"))
+		s.writeString("\n")
+	}
+}
+
+func (s *dumpState) inlineComment(comment string) {
+	if s.config.Compact {
+		s.writeString(fmt.Sprintf("/*%s*/", comment))
+	} else {
+		s.writeString(fmt.Sprintf(" /* %s */", comment))
 	}
 }
 
+// blockComment writes a comment spanning several lines after a value, with the lines indented one
+// level deeper than the value.
+func (s *dumpState) blockComment(title string, lines []string) {
+	indent := strings.Repeat("  ", s.depth+1)
+	s.writeString(" /* " + title + "\n" + indent + strings.Join(lines, "\n"+indent) + "\n" + strings.Repeat("  ", s.depth) + "*/")
+}
+
 func (s *dumpState) dumpType(v reflect.Value) {
-	typeName := v.Type().String()
+	if s.omitType {
+		s.omitType = false
+		return
+	}
+	name := s.typeName(v.Type())
+	if s.typeUses != nil {
+		if s.typeUses[name] == 0 {
+			s.typeOrder = append(s.typeOrder, name)
+		}
+		s.typeUses[name]++
+	} else if alias, ok := s.typeAliases[name]; ok {
+		name = alias
+	}
+	s.writeString(name)
+}
+
+// abbreviateTypes assigns aliases to the type names used more than once when dumping the value,
+// and writes the legend of the aliases.
+func (s *dumpState) abbreviateTypes(value interface{}) {
+	counter := newDumpState(reflect.ValueOf(value), s.config, io.Discard)
+	counter.typeUses = make(map[string]int)
+	counter.typeAliases = make(map[string]string)
+	counter.dump(value)
+
+	s.typeAliases = make(map[string]string)
+	for _, name := range counter.typeOrder {
+		alias := fmt.Sprintf("T%d", len(s.typeAliases))
+		if counter.typeUses[name] < 2 || len(name) <= len(alias) {
+			continue
+		}
+		s.typeAliases[name] = alias
+		if s.config.Compact {
+			s.writeString("/*" + alias + "=" + name + "*/")
+		} else {
+			s.writeString("// " + alias + " = " + name + "\n")
+		}
+	}
+}
+
+// dumpPointerPreamble writes the reused pointers of the value as definitions like p0 := &T{...},
+// in the order they are first encountered, so that the value itself only refers to them by label.
+func (s *dumpState) dumpPointerPreamble(v reflect.Value) {
+	collector := newDumpState(v, s.config, io.Discard)
+	collector.collectPointers = true
+	collector.dumpVal(v)
+
+	for _, p := range collector.pointerOrder {
+		if s.visitedPointers.contains(p) {
+			// Defined inside the definition of an earlier pointer.
+			continue
+		}
+		ptr, _ := s.pointers.get(p)
+		if s.config.Compact {
+			s.writeString(s.pointerLabel(ptr) + ":=")
+		} else {
+			s.writeString(s.pointerLabel(ptr) + " := ")
+		}
+		s.definingPointer = true
+		s.dumpVal(p)
+		s.definingPointer = false
+		if s.config.Compact {
+			s.writeString(";")
+		} else {
+			s.newlineWithPointerNameComment()
+		}
+	}
+}
+
+// typeName returns the name of a type as dumped: as given by TypeNameFunc if set, or otherwise with
+// the package name options applied.
+func (s *dumpState) typeName(t reflect.Type) string {
+	if s.config.TypeNameFunc != nil {
+		return s.config.TypeNameFunc(t)
+	}
+	return s.qualifiedName(t.String())
+}
+
+// qualifiedName applies the package name options to a type or function name.
+func (s *dumpState) qualifiedName(name string) string {
 	if s.config.StripPackageNames {
-		typeName = packageNameStripperRegexp.ReplaceAllLiteralString(typeName, "")
+		name = packageNameStripperRegexp.ReplaceAllLiteralString(name, "")
 	} else if s.homePackageRegexp != nil {
-		typeName = s.homePackageRegexp.ReplaceAllLiteralString(typeName, "")
+		name = s.homePackageRegexp.ReplaceAllLiteralString(name, "")
 	}
 	if s.config.Compact {
-		typeName = compactTypeRegexp.ReplaceAllString(typeName, "$1")
+		name = compactTypeRegexp.ReplaceAllString(name, "$1")
 	}
-	s.write([]byte(typeName))
+	return name
 }
 
-func (s *dumpState) dumpSlice(v reflect.Value) {
+// summarize dumps the collection as its type and length if it has more than SummarizeAbove
+// elements. Returns false if the collection should be dumped in full.
+func (s *dumpState) summarize(v reflect.Value, length int) bool {
+	if s.config.SummarizeAbove <= 0 || length <= s.config.SummarizeAbove {
+		return false
+	}
 	s.dumpType(v)
+	s.writeString(fmt.Sprintf("(len=%d)", length))
+	s.truncated("SummarizeAbove")
+	return true
+}
+
+func (s *dumpState) dumpSlice(v reflect.Value) {
+	if s.config.ShowSizes {
+		defer s.dumpSize(v)
+	}
+	if s.backingArrays != nil && v.Kind() == reflect.Slice {
+		if path, ok := s.sharedBackingArray(v); ok {
+			defer s.inlineComment("shares backing array with " + path)
+		}
+	}
 	numEntries := v.Len()
+	if s.summarize(v, numEntries) {
+		return
+	}
+	s.dumpType(v)
 	if numEntries == 0 {
-		s.write([]byte("{}"))
+		s.writeString("{}")
+		return
+	}
+	if s.isTabular(v.Type().Elem()) {
+		s.dumpTable(v)
 		return
 	}
-	s.write([]byte("{"))
+	order := s.sliceOrder(v)
+	if s.isInlineSlice(v) {
+		s.writeString("{")
+		for n := 0; n < numEntries; n++ {
+			i := n
+			if order != nil {
+				i = order[n]
+			}
+			if n > 0 {
+				s.writeString(", ")
+			}
+			s.pushIndex(i)
+			s.dumpVal(v.Index(i))
+			s.popPath()
+		}
+		s.writeString("}")
+		return
+	}
+	s.writeString("{")
 	s.newlineWithPointerNameComment()
 	s.depth++
-	for i := 0; i < numEntries; i++ {
+	for n := 0; n < numEntries; n++ {
+		i := n
+		if order != nil {
+			i = order[n]
+		}
 		s.indent()
+		s.pushIndex(i)
 		s.dumpVal(v.Index(i))
-		if !s.config.Compact || i < numEntries-1 {
-			s.write([]byte(","))
+		s.popPath()
+		if !s.config.Compact || n < numEntries-1 {
+			s.writeString(",")
 		}
 		s.newlineWithPointerNameComment()
 	}
 	s.depth--
 	s.indent()
-	s.write([]byte("}"))
+	s.writeString("}")
+}
+
+// backingArray is the memory a dumped slice can reach within its capacity, for ShowSliceSharing.
+type backingArray struct {
+	start, end uintptr
+	path       string
+}
+
+// sharedBackingArray returns the path of the first slice dumped before whose backing array overlaps
+// with the one of the given slice, and records the slice for the slices dumped after it.
+func (s *dumpState) sharedBackingArray(v reflect.Value) (string, bool) {
+	size := v.Type().Elem().Size()
+	if v.IsNil() || v.Cap() == 0 || size == 0 {
+		return "", false
+	}
+	start := v.Pointer()
+	end := start + uintptr(v.Cap())*size
+	path := s.pathString()
+	for _, b := range *s.backingArrays {
+		// The path is the same when dumping again after trying CompactThreshold.
+		if b.start < end && start < b.end && b.path != path {
+			return b.path, true
+		}
+	}
+	*s.backingArrays = append(*s.backingArrays, backingArray{start: start, end: end, path: path})
+	return "", false
+}
+
+// sliceOrder returns the indices of the elements of a slice in the order SortSlices dumps them in,
+// or nil if the elements are dumped in their own order. Numbers are sorted by value and other
+// elements by their dumps, keeping equal elements in their own order.
+func (s *dumpState) sliceOrder(v reflect.Value) []int {
+	if !s.config.SortSlices || v.Kind() != reflect.Slice {
+		return nil
+	}
+	order := make([]int, v.Len())
+	for i := range order {
+		order[i] = i
+	}
+	numbers := make([]float64, len(order))
+	numeric := true
+	for i := range order {
+		if numbers[i], numeric = numericValue(deInterface(v.Index(i))); !numeric {
+			break
+		}
+	}
+	if numeric {
+		sort.SliceStable(order, func(i, j int) bool {
+			return numbers[order[i]] < numbers[order[j]]
+		})
+		return order
+	}
+	options := s.config.comparison()
+	dumps := make([]string, len(order))
+	for i := range order {
+		e := v.Index(i)
+		buf := new(bytes.Buffer)
+		newDumpState(e, options, buf).dumpVal(e)
+		dumps[i] = buf.String()
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return dumps[order[i]] < dumps[order[j]]
+	})
+	return order
+}
+
+// isTabular returns true if slices of the given element type should be dumped as a table.
+func (s *dumpState) isTabular(t reflect.Type) bool {
+	if !s.config.TabularSlices || s.config.Compact || s.config.hasDumpFunc() {
+		return false
+	}
+	if t.Kind() != reflect.Struct || t == timeType || isCustomDumper(t) {
+		return false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if !isScalarKind(t.Field(i).Type.Kind()) {
+			return false
+		}
+	}
+	return true
+}
+
+// dumpTable dumps the elements of a slice or array of structs as rows of a table. The fields of
+// the rows are rendered first, to measure the width of the columns.
+func (s *dumpState) dumpTable(v reflect.Value) {
+	t := v.Type().Elem()
+	rows := make([][]string, v.Len())
+	widths := make([]int, t.NumField())
+	w := s.w
+	order := s.sliceOrder(v)
+	for i := range rows {
+		index := i
+		if order != nil {
+			index = order[i]
+		}
+		row := v.Index(index)
+		rows[i] = make([]string, t.NumField())
+		s.pushIndex(index)
+		for _, f := range s.visibleFields(row) {
+			name := t.Field(f).Name
+			buf := new(bytes.Buffer)
+			s.w = buf
+			s.inField(row, f, s.dumpVal)
+			cell := name + ": " + buf.String()
+			rows[i][f] = cell
+			if n := utf8.RuneCountInString(cell); n > widths[f] {
+				widths[f] = n
+			}
+		}
+		s.popPath()
+	}
+	s.w = w
+
+	s.writeString("{")
+	s.newlineWithPointerNameComment()
+	s.depth++
+	for _, cells := range rows {
+		last := -1
+		for f, cell := range cells {
+			if cell != "" {
+				last = f
+			}
+		}
+		line := "{"
+		for f := 0; f < last; f++ {
+			if widths[f] == 0 {
+				continue
+			}
+			cell := cells[f]
+			if cell != "" {
+				cell += ","
+			}
+			line += cell + strings.Repeat(" ", widths[f]+2-utf8.RuneCountInString(cell))
+		}
+		if last >= 0 {
+			line += cells[last]
+		}
+		s.indent()
+		s.writeString(line + "},")
+		s.newlineWithPointerNameComment()
+	}
+	s.depth--
+	s.indent()
+	s.writeString("}")
 }
 
 func (s *dumpState) dumpStruct(v reflect.Value) {
-	dumpPreamble := func() {
+	if s.config.ShowSizes {
+		defer s.dumpSize(v)
+	}
+	fields := s.visibleFields(v)
+	methods := s.methodSignatures(v.Type())
+	if len(fields) == 0 && len(methods) == 0 {
+		// There are no fields to dump
 		s.dumpType(v)
-		s.write([]byte("{"))
+		s.writeString("{}")
+		return
+	}
+
+	s.dumpType(v)
+	s.writeString("{")
+	vt := v.Type()
+	positional := s.config.PositionalStructs && len(fields) == vt.NumField()
+	if len(methods) == 0 && s.isInlineStruct(v, fields) {
+		for n, i := range fields {
+			if n > 0 {
+				s.writeString(", ")
+			}
+			if !positional {
+				s.writeString(vt.Field(i).Name + ": ")
+			}
+			s.dumpField(v, i)
+		}
+		s.writeString("}")
+		return
+	}
+
+	s.newlineWithPointerNameComment()
+	s.depth++
+	for n, i := range fields {
+		vtf := vt.Field(i)
+		s.indent()
+		if !positional {
+			s.writeString(vtf.Name)
+			if s.config.Compact {
+				s.writeString(":")
+			} else {
+				s.writeString(": ")
+			}
+		}
+		s.dumpField(v, i)
+		if !s.config.Compact || n < len(fields)-1 {
+			s.writeString(",")
+		}
+		if s.config.ShowFieldLayout {
+			s.newlineWithComment(fmt.Sprintf("offset=%d size=%d", vtf.Offset, vtf.Type.Size()))
+		} else {
+			s.newlineWithPointerNameComment()
+		}
+	}
+	for _, method := range methods {
+		if s.config.Compact {
+			s.writeString("/*" + method + "*/")
+			continue
+		}
+		s.indent()
+		s.writeString("// " + method)
 		s.newlineWithPointerNameComment()
-		s.depth++
 	}
-	preambleDumped := false
+	s.depth--
+	s.indent()
+	s.writeString("}")
+}
+
+// methodSignatures returns the signatures of the exported methods of the type, including those
+// with pointer receivers, for ShowMethods.
+func (s *dumpState) methodSignatures(t reflect.Type) []string {
+	if !s.config.ShowMethods {
+		return nil
+	}
+	pt := reflect.PtrTo(t)
+	signatures := make([]string, 0, pt.NumMethod())
+	for i := 0; i < pt.NumMethod(); i++ {
+		m := pt.Method(i)
+		signature := m.Type.String()
+		// Replace the receiver parameter with the method name
+		if strings.HasPrefix(signature, "func("+pt.String()) {
+			signature = strings.TrimPrefix(signature, "func("+pt.String())
+			signature = "(" + strings.TrimPrefix(signature, ", ")
+		}
+		signatures = append(signatures, m.Name+s.qualifiedName(signature))
+	}
+	return signatures
+}
+
+// dumpField dumps the value of the struct field with the given index.
+func (s *dumpState) dumpField(v reflect.Value, i int) {
+	s.inField(v, i, s.dumpVal)
+}
+
+// inField calls f with the value of the struct field with the given index, with the path set to
+// the field, and strings masked if the field has the `litter:"mask"` tag.
+func (s *dumpState) inField(v reflect.Value, i int, f func(reflect.Value)) {
+	vtf := v.Type().Field(i)
+	s.pushField(vtf.Name)
+	maskStrings := s.maskStrings
+	if vtf.Tag.Get("litter") == "mask" {
+		s.maskStrings = true
+	}
+	f(v.Field(i))
+	s.maskStrings = maskStrings
+	s.popPath()
+}
+
+// isInlineSlice returns true if the slice or array should be dumped on a single line for
+// ScalarArrayInline, which is when its elements are numbers or booleans and there are few enough.
+func (s *dumpState) isInlineSlice(v reflect.Value) bool {
+	if s.config.ScalarArrayInline <= 0 || s.config.Compact || v.Len() > s.config.ScalarArrayInline {
+		return false
+	}
+	t := v.Type().Elem()
+	if t.Kind() == reflect.String || !isScalarKind(t.Kind()) || isCustomDumper(t) || s.config.hasDumpFunc() {
+		return false
+	}
+	return true
+}
+
+// isInlineStruct returns true if the struct should be dumped on a single line for
+// InlineScalarStructs, which is when all the given fields hold scalars.
+func (s *dumpState) isInlineStruct(v reflect.Value, fields []int) bool {
+	if !s.config.InlineScalarStructs || s.config.Compact || s.config.ShowFieldLayout {
+		return false
+	}
+	for _, i := range fields {
+		if !isScalarKind(deInterface(v.Field(i)).Kind()) {
+			return false
+		}
+	}
+	return true
+}
+
+// visibleFields returns the indices of the fields of the struct that should be dumped.
+func (s *dumpState) visibleFields(v reflect.Value) []int {
 	vt := v.Type()
+	hidePrivateFields := s.hidePrivateFields(vt)
 	numFields := v.NumField()
+	fields := make([]int, 0, numFields)
 	for i := 0; i < numFields; i++ {
 		vtf := vt.Field(i)
-		if s.config.HidePrivateFields && vtf.PkgPath != "" || s.config.FieldExclusions != nil && s.config.FieldExclusions.MatchString(vtf.Name) {
+		if s.config.FieldInclusions != nil && !s.config.FieldInclusions.MatchString(vtf.Name) {
+			continue
+		}
+		if hidePrivateFields && vtf.PkgPath != "" || s.config.FieldExclusions != nil && s.config.FieldExclusions.MatchString(vtf.Name) {
 			continue
 		}
 		if s.config.FieldFilter != nil && !s.config.FieldFilter(vtf, v.Field(i)) {
 			continue
 		}
+		if s.config.HideFuncFields && vtf.Type.Kind() == reflect.Func {
+			continue
+		}
+		if s.config.HideKinds != nil && s.isKindHidden(v.Field(i)) {
+			continue
+		}
 		if s.config.HideZeroValues && isZeroValue(v.Field(i)) {
 			continue
 		}
-		if !preambleDumped {
-			dumpPreamble()
-			preambleDumped = true
+		if s.config.HideEmptyStrings && vtf.Type.Kind() == reflect.String && v.Field(i).Len() == 0 {
+			continue
 		}
-		s.indent()
-		s.write([]byte(vtf.Name))
-		if s.config.Compact {
-			s.write([]byte(":"))
-		} else {
-			s.write([]byte(": "))
+		if s.isPathExcluded(vtf.Name) {
+			continue
 		}
-		s.dumpVal(v.Field(i))
-		if !s.config.Compact || i < numFields-1 {
-			s.write([]byte(","))
+		fields = append(fields, i)
+	}
+	return fields
+}
+
+// isKindHidden returns true if the kind of the field, or of the value held by an interface field, is
+// in HideKinds.
+func (s *dumpState) isKindHidden(v reflect.Value) bool {
+	for _, kind := range s.config.HideKinds {
+		if v.Kind() == kind || deInterface(v).Kind() == kind {
+			return true
 		}
-		s.newlineWithPointerNameComment()
 	}
-	if preambleDumped {
-		s.depth--
-		s.indent()
-		s.write([]byte("}"))
-	} else {
-		// There were no fields dumped
-		s.dumpType(v)
-		s.write([]byte("{}"))
+	return false
+}
+
+// isRedacted returns true if values of the type are listed in RedactTypes.
+func (s *dumpState) isRedacted(t reflect.Type) bool {
+	for _, redacted := range s.config.RedactTypes {
+		if redacted == t {
+			return true
+		}
 	}
+	return false
+}
+
+// hidePrivateFields returns true if the private fields of the given struct type should be hidden.
+func (s *dumpState) hidePrivateFields(t reflect.Type) bool {
+	if !s.config.HidePrivateFields {
+		return false
+	}
+	for _, shown := range s.config.ShowPrivateFor {
+		if shown == t {
+			return false
+		}
+	}
+	return true
 }
 
 func (s *dumpState) dumpMap(v reflect.Value) {
 	if v.IsNil() {
-		s.dumpType(v)
+		s.dumpMapType(v)
 		s.writeString("(nil)")
 		return
 	}
 
-	s.dumpType(v)
+	if s.config.ShowSizes {
+		defer s.dumpSize(v)
+	}
+	if s.summarize(v, v.Len()) {
+		return
+	}
 
-	keys := v.MapKeys()
+	s.dumpMapType(v)
+
+	keys, omitted := s.dumpedMapKeys(v)
 	if len(keys) == 0 {
-		s.write([]byte("{}"))
+		s.writeString("{}")
 		return
 	}
 
-	s.write([]byte("{"))
+	s.writeString("{")
 	s.newlineWithPointerNameComment()
 	s.depth++
-	sort.Sort(mapKeySorter{
-		keys:    keys,
-		options: s.config,
-	})
 	numKeys := len(keys)
 	for i, key := range keys {
 		s.indent()
-		s.dumpVal(key)
-		if s.config.Compact {
-			s.write([]byte(":"))
+		if s.config.MapsAsPairs {
+			s.writeString(s.compactable("{Key: "))
+		}
+		s.dumpMapKey(key)
+		if s.config.MapsAsPairs {
+			s.writeString(s.compactable(", Value: "))
 		} else {
-			s.write([]byte(": "))
+			s.writeString(s.compactable(": "))
 		}
+		s.pushKey(key)
 		s.dumpVal(v.MapIndex(key))
+		s.popPath()
+		if s.config.MapsAsPairs {
+			s.writeString("}")
+		}
 		if !s.config.Compact || i < numKeys-1 {
-			s.write([]byte(","))
+			s.writeString(",")
+		}
+		s.newlineWithPointerNameComment()
+	}
+	if omitted > 0 {
+		if s.config.Compact {
+			s.writeString(fmt.Sprintf("/*... %d more*/", omitted))
+		} else {
+			s.indent()
+			s.writeString(fmt.Sprintf("// ... %d more\n", omitted))
+		}
+	}
+	s.depth--
+	s.indent()
+	s.writeString("}")
+}
+
+// dumpMapType dumps the type of the map, or the type of the slice of pairs it is dumped as if
+// MapsAsPairs is set.
+func (s *dumpState) dumpMapType(v reflect.Value) {
+	if !s.config.MapsAsPairs {
+		s.dumpType(v)
+		return
+	}
+	pair := reflect.StructOf([]reflect.StructField{
+		{Name: "Key", Type: v.Type().Key()},
+		{Name: "Value", Type: v.Type().Elem()},
+	})
+	s.dumpType(reflect.Zero(reflect.SliceOf(pair)))
+}
+
+// compactable returns the text with its spaces removed if Compact is set.
+func (s *dumpState) compactable(text string) string {
+	if s.config.Compact {
+		return strings.Replace(text, " ", "", -1)
+	}
+	return text
+}
+
+// dumpedMapKeys returns the keys of the visible entries of the map in the order they are dumped,
+// up to MaxMapLength, and the number of entries omitted beyond it.
+func (s *dumpState) dumpedMapKeys(v reflect.Value) ([]reflect.Value, int) {
+	keys := s.orderMapKeys(v, s.visibleMapKeys(v))
+	if s.config.MaxMapLength <= 0 || len(keys) <= s.config.MaxMapLength {
+		return keys, 0
+	}
+	s.truncated("MaxMapLength")
+	return keys[:s.config.MaxMapLength], len(keys) - s.config.MaxMapLength
+}
+
+// visibleMapKeys returns the keys of the map entries that should be dumped, skipping entries with
+// zero values if HideZeroValues is set.
+func (s *dumpState) visibleMapKeys(v reflect.Value) []reflect.Value {
+	keys := v.MapKeys()
+	if !s.config.HideZeroValues {
+		return keys
+	}
+	visible := keys[:0]
+	for _, key := range keys {
+		if !isZeroValue(v.MapIndex(key)) {
+			visible = append(visible, key)
+		}
+	}
+	return visible
+}
+
+func (s *dumpState) dumpMapKey(key reflect.Value) {
+	if s.config.MapKeyDumpFunc != nil {
+		v := deInterface(key)
+		buf := new(bytes.Buffer)
+		if s.config.MapKeyDumpFunc(v, buf) {
+			s.dumpCustom(v, buf)
+			return
+		}
+	}
+	if s.config.DerefMapKeys {
+		key = derefPointers(key)
+	}
+	if s.config.UnquoteIdentKeys {
+		if v := deInterface(key); v.Kind() == reflect.String && identifierRegexp.MatchString(v.String()) &&
+			!s.isRedacted(v.Type()) {
+			s.writeString(v.String())
+			return
+		}
+	}
+	s.mapKey = key
+	s.dumpVal(key)
+}
+
+// orderMapKeys sorts the keys of the map, honoring the order given by the map if it implements
+// KeyOrderer.
+func (s *dumpState) orderMapKeys(v reflect.Value, keys []reflect.Value) []reflect.Value {
+	if v.CanInterface() {
+		if orderer, ok := v.Interface().(KeyOrderer); ok {
+			return s.orderedMapKeys(v, keys, orderer.LitterKeys())
+		}
+	}
+	sort.Sort(s.mapKeySorter(v, keys))
+	if s.config.SortMapsByValue {
+		sort.Stable(mapValueSorter{
+			keys:    keys,
+			m:       v,
+			options: s.config.comparison(),
+		})
+	}
+	return keys
+}
+
+// orderedMapKeys returns the keys of the map in the order given by hint, followed by any remaining
+// keys in sorted order.
+func (s *dumpState) orderedMapKeys(v reflect.Value, keys []reflect.Value, hint []interface{}) []reflect.Value {
+	keyType := v.Type().Key()
+	result := make([]reflect.Value, 0, len(keys))
+	seen := make(map[interface{}]bool, len(keys))
+	for _, k := range hint {
+		kv := reflect.ValueOf(k)
+		if !kv.IsValid() || !kv.Type().AssignableTo(keyType) {
+			continue
+		}
+		if seen[kv.Interface()] || !v.MapIndex(kv).IsValid() {
+			continue
+		}
+		seen[kv.Interface()] = true
+		result = append(result, kv)
+	}
+
+	rest := make([]reflect.Value, 0, len(keys)-len(result))
+	for _, k := range keys {
+		if !k.CanInterface() || !seen[k.Interface()] {
+			rest = append(rest, k)
+		}
+	}
+	sort.Sort(s.mapKeySorter(v, rest))
+	return append(result, rest...)
+}
+
+// mapKeySorter returns a sorter for the keys of the map, breaking ties by value for
+// CanonicalLabels.
+func (s *dumpState) mapKeySorter(v reflect.Value, keys []reflect.Value) mapKeySorter {
+	sorter := mapKeySorter{
+		keys:    keys,
+		options: s.config.comparison(),
+	}
+	if s.config.CanonicalLabels {
+		sorter.m = v
+	}
+	return sorter
+}
+
+// dumpEnum dumps an integer of a type listed in EnumNames as a conversion, followed by the name of
+// the value as a comment. Returns false if the type is not listed.
+func (s *dumpState) dumpEnum(v reflect.Value, n int64) bool {
+	names, ok := s.config.EnumNames[v.Type()]
+	if !ok {
+		return false
+	}
+	s.dumpType(v)
+	s.writeString("(")
+	if v.Kind() >= reflect.Uint && v.Kind() <= reflect.Uint64 {
+		printUint(s, v.Uint(), 10)
+	} else {
+		printInt(s, n, 10)
+	}
+	s.writeString(")")
+	if name, ok := names[n]; ok {
+		s.inlineComment(name)
+	}
+	return true
+}
+
+// dumpFlags dumps an integer of a type listed in FlagNames as a conversion of its flags OR'ed
+// together. Returns false if the type is not listed.
+func (s *dumpState) dumpFlags(v reflect.Value, n uint64) bool {
+	names, ok := s.config.FlagNames[v.Type()]
+	if !ok {
+		return false
+	}
+	flags := make([]uint64, 0, len(names))
+	for flag := range names {
+		if flag != 0 {
+			flags = append(flags, flag)
+		}
+	}
+	sort.Slice(flags, func(i, j int) bool { return flags[i] < flags[j] })
+
+	var parts []string
+	for _, flag := range flags {
+		if n&flag == flag {
+			parts = append(parts, names[flag])
+			n &^= flag
+		}
+	}
+	if n != 0 || len(parts) == 0 {
+		parts = append(parts, strconv.FormatUint(n, 10))
+	}
+	s.dumpType(v)
+	s.writeString("(" + strings.Join(parts, "|") + ")")
+	return true
+}
+
+// showScalarType returns true if the value is of a named scalar type whose name should be shown
+// for ShowScalarTypes. Enums and uintptrs are excluded, since their type is always shown.
+func (s *dumpState) showScalarType(v reflect.Value) bool {
+	if !s.config.ShowScalarTypes || !isScalarKind(v.Kind()) || v.Kind() == reflect.Uintptr {
+		return false
+	}
+	if _, ok := s.config.EnumNames[v.Type()]; ok {
+		return false
+	}
+	return v.Type().PkgPath() != ""
+}
+
+// dumpPointerAddress dumps a pointer beyond MaxPointerDepth as its label, if it was dumped before,
+// or as its address, like (*int)(0xc000012345).
+func (s *dumpState) dumpPointerAddress(v reflect.Value) {
+	if ptr, ok := s.pointers.get(v); ok && s.visitedPointers.contains(v) {
+		s.writeString(s.pointerLabel(ptr))
+		return
+	}
+	s.writeString("(")
+	s.dumpType(v)
+	s.writeString(")(0x")
+	printUint(s, uint64(v.Pointer()), 16)
+	s.writeString(")")
+}
+
+// dumpUintptr dumps a uintptr in hexadecimal as a conversion, followed by its name as a comment
+// if UintptrNames resolves it.
+func (s *dumpState) dumpUintptr(v reflect.Value) {
+	s.dumpType(v)
+	s.writeString("(0x")
+	printUint(s, v.Uint(), 16)
+	s.writeString(")")
+	if s.config.UintptrNames != nil {
+		if name, ok := s.config.UintptrNames(uintptr(v.Uint())); ok {
+			s.inlineComment(name)
+		}
+	}
+}
+
+// groupDigits writes the given integer with thousands separators as a comment, if GroupDigits is
+// set and the integer has more than three digits.
+func (s *dumpState) groupDigits(digits string) {
+	if !s.config.GroupDigits {
+		return
+	}
+	if grouped := groupDigits(digits); grouped != digits {
+		s.inlineComment(grouped)
+	}
+}
+
+func (s *dumpState) dumpString(v reflect.Value) {
+	str := v.String()
+	if s.masks(v) {
+		s.dumpType(v)
+		s.writeString(fmt.Sprintf("(len=%d)", len(str)))
+		return
+	}
+	if info, firstVisit := s.strings.visit(str); info != nil {
+		label := info.label("s")
+		if !firstVisit {
+			s.writeString(label)
+			return
 		}
-		s.newlineWithPointerNameComment()
+		s.writeString(strconv.Quote(str))
+		s.inlineComment(label)
+		return
 	}
-	s.depth--
-	s.indent()
-	s.write([]byte("}"))
+	s.writeString(strconv.Quote(str))
 }
 
 func (s *dumpState) dumpFunc(v reflect.Value) {
-	parts := strings.Split(runtime.FuncForPC(v.Pointer()).Name(), "/")
+	if v.IsNil() {
+		s.dumpTypedNil(v)
+		return
+	}
+	fn := runtime.FuncForPC(v.Pointer())
+	parts := strings.Split(fn.Name(), "/")
 	name := parts[len(parts)-1]
 
-	// Anonymous function
-	if strings.Count(name, ".") > 1 {
+	// Method values, like value.Method, are wrappers named after the method with a -fm suffix
+	name = strings.TrimSuffix(name, "-fm")
+
+	if anonymousFuncRegexp.MatchString(name) {
 		s.dumpType(v)
+	} else if strings.Count(name, ".") > 1 {
+		s.writeString(s.methodName(name))
 	} else {
-		if s.config.StripPackageNames {
-			name = packageNameStripperRegexp.ReplaceAllLiteralString(name, "")
-		} else if s.homePackageRegexp != nil {
-			name = s.homePackageRegexp.ReplaceAllLiteralString(name, "")
-		}
-		if s.config.Compact {
-			name = compactTypeRegexp.ReplaceAllString(name, "$1")
-		}
-		s.write([]byte(name))
+		s.writeString(s.qualifiedName(name))
+	}
+
+	if s.config.ShowFuncLocation && fn != nil {
+		file, line := fn.FileLine(fn.Entry())
+		s.inlineComment(fmt.Sprintf("%s:%d", filepath.Base(file), line))
 	}
 }
 
+// methodName turns the runtime name of a method, like pkg.(*T).Method, into a method expression
+// like (*pkg.T).Method.
+func (s *dumpState) methodName(name string) string {
+	dot := strings.Index(name, ".")
+	last := strings.LastIndex(name, ".")
+	receiver := name[dot+1 : last]
+	if strings.HasPrefix(receiver, "(*") {
+		receiver = strings.TrimSuffix(strings.TrimPrefix(receiver, "(*"), ")")
+		return "(*" + s.qualifiedName(name[:dot+1]+receiver) + ")" + name[last:]
+	}
+	return s.qualifiedName(name[:dot+1]+receiver) + name[last:]
+}
+
+// dumpTypedNil dumps a nil func or channel as a conversion of nil to its type, like
+// (func())(nil), parenthesized since the type could not be converted to otherwise.
+func (s *dumpState) dumpTypedNil(v reflect.Value) {
+	s.writeString("(")
+	s.dumpType(v)
+	s.writeString(")(nil)")
+}
+
 func (s *dumpState) dumpChan(v reflect.Value) {
+	if v.IsNil() {
+		s.dumpTypedNil(v)
+		return
+	}
 	vType := v.Type()
 	res := []byte(vType.String())
 	s.write(res)
@@ -250,52 +1566,167 @@ func (s *dumpState) dumpCustom(v reflect.Value, buf *bytes.Buffer) {
 	// Dump the type
 	s.dumpType(v)
 
+	s.writeReindented(buf.String())
+}
+
+// writeReindented writes the multi-line output of a custom dumper or formatter, replacing its own
+// indentation with the current one.
+func (s *dumpState) writeReindented(text string) {
 	if s.config.Compact {
-		s.write(buf.Bytes())
+		s.writeString(text)
 		return
 	}
 
+	// Only the part of the dump that fits within MaxOutputBytes can be written, so there is no
+	// need to split the rest of a large dump into lines.
+	if s.output != nil && len(text) > s.output.remaining {
+		text = text[:s.output.remaining+1]
+	}
+
 	// Now output the dump taking care to apply the current indentation-level
 	// and pointer name comments.
-	var err error
-	firstLine := true
-	for err == nil {
-		var lineBytes []byte
-		lineBytes, err = buf.ReadBytes('\n')
-		line := strings.TrimRight(string(lineBytes), " \n")
-
-		if err != nil && err != io.EOF {
-			break
+	for i, line := range dedent(strings.Split(text, "\n")) {
+		line = strings.TrimRight(line, " ")
+		// Do not indent first line, nor blank lines
+		if i > 0 {
+			s.newlineWithPointerNameComment()
+			if line != "" {
+				s.indent()
+			}
 		}
-		// Do not indent first line
-		if firstLine {
-			firstLine = false
-		} else {
-			s.indent()
+		s.writeString(line)
+	}
+}
+
+// dumpIndentedCustom writes the output of an IndentedDumper after the type, adding the pointer name
+// comment to the first line but otherwise leaving the output as is.
+func (s *dumpState) dumpIndentedCustom(v reflect.Value, buf *bytes.Buffer) {
+	s.dumpType(v)
+	if s.config.Compact {
+		s.write(buf.Bytes())
+		return
+	}
+	for i, line := range strings.Split(buf.String(), "\n") {
+		if i > 0 {
+			s.newlineWithPointerNameComment()
 		}
-		s.write([]byte(line))
+		s.writeString(strings.TrimRight(line, " "))
+	}
+}
 
-		// At EOF we're done
-		if err == io.EOF {
-			return
+// dedent removes the leading whitespace of the first line, which follows the type name, and the
+// leading whitespace common to all other non-blank lines, so that custom dumpers emitting indented
+// output are not indented twice.
+func dedent(lines []string) []string {
+	lines[0] = strings.TrimLeft(lines[0], " \t")
+	common := ""
+	first := true
+	for _, line := range lines[1:] {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if first {
+			common, first = indent, false
+			continue
+		}
+		for !strings.HasPrefix(indent, common) {
+			common = common[:len(common)-1]
 		}
-		s.newlineWithPointerNameComment()
 	}
-	panic(err)
+	if common == "" {
+		return lines
+	}
+	for i := 1; i < len(lines); i++ {
+		lines[i] = strings.TrimPrefix(lines[i], common)
+	}
+	return lines
 }
 
 func (s *dumpState) dump(value interface{}) {
+	if s.config.SchemaOnly {
+		s.dumpSchema(reflect.TypeOf(value))
+		return
+	}
+	switch s.config.Format {
+	case FormatYAML:
+		s.dumpYAML(addressable(reflect.ValueOf(value)))
+		return
+	case FormatRepr:
+		s.dumpRepr(addressable(reflect.ValueOf(value)))
+		return
+	case FormatLogfmt:
+		s.dumpLogfmt(addressable(reflect.ValueOf(value)))
+		return
+	case FormatFlatPaths:
+		s.dumpFlatPaths(addressable(reflect.ValueOf(value)))
+		return
+	}
+	if s.config.Gofmt || s.config.Color {
+		w := s.w
+		buf := new(bytes.Buffer)
+		s.w = buf
+		s.dumpGo(value)
+		s.w = w
+		out := buf.Bytes()
+		if s.config.Gofmt {
+			if formatted, err := format.Source(out); err == nil {
+				out = formatted
+			}
+		}
+		if s.config.Color {
+			out = colorize(out)
+		}
+		s.write(out)
+		return
+	}
+	s.dumpGo(value)
+}
+
+// dumpGo dumps the value as Go literals.
+func (s *dumpState) dumpGo(value interface{}) {
 	if value == nil {
-		printNil(s.w)
+		if s.varName != "" {
+			s.writeString("var " + s.varName + " interface{} = ")
+		}
+		printNil(s)
 		return
 	}
-	v := reflect.ValueOf(value)
+	if s.config.AbbreviateTypes && s.typeAliases == nil {
+		s.abbreviateTypes(value)
+	}
+	v := addressable(reflect.ValueOf(value))
+	if s.config.PointerPreamble && !s.config.DisablePointerReplacement {
+		s.dumpPointerPreamble(v)
+	}
+	if s.varName != "" {
+		s.writeString("var " + s.varName + " = ")
+	}
+	s.omitType = s.config.HideTopLevelType && hasTypeName(v)
 	s.dumpVal(v)
 }
 
+// hasTypeName returns true if dumping the value starts with its type name, possibly after
+// pointer dereferences.
+func hasTypeName(v reflect.Value) bool {
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Map, reflect.Slice:
+		return !v.IsNil()
+	case reflect.Array, reflect.Struct:
+		return true
+	}
+	return false
+}
+
 func (s *dumpState) descendIntoPossiblePointer(value reflect.Value, f func()) {
 	canonicalize := true
+	circular := false
 	if isPointerValue(value) {
+		circular = s.parentPointers.contains(value)
+
 		// If elision disabled, and this is not a circular reference, don't canonicalize
 		if s.config.DisablePointerReplacement && s.parentPointers.add(value) {
 			canonicalize = false
@@ -319,21 +1750,117 @@ func (s *dumpState) descendIntoPossiblePointer(value reflect.Value, f func()) {
 		return
 	}
 	if firstVisit {
-		s.currentPointer = ptr
+		if s.collectPointers {
+			s.pointerOrder = append(s.pointerOrder, value)
+		}
+		// The label of a pointer defined in the preamble precedes it already.
+		if !s.definingPointer {
+			s.currentPointer = ptr
+		}
+		s.definingPointer = false
 		f()
 		return
 	}
-	s.write([]byte(ptr.label()))
+	label := s.pointerLabel(ptr)
+	s.writeString(label)
+	if s.config.ExplainElisions {
+		if circular {
+			s.inlineComment("elided: circular reference to " + label)
+		} else {
+			s.inlineComment("elided: reused pointer " + label)
+		}
+	}
 }
 
 func (s *dumpState) dumpVal(value reflect.Value) {
-	if value.Kind() == reflect.Ptr && value.IsNil() {
-		s.write([]byte("nil"))
+	s.recursion++
+	defer func() { s.recursion-- }()
+	// The number of pointers dereferenced in a row to get to this value, for MaxPointerDepth.
+	pointerChain := s.pointerChain
+	s.pointerChain = 0
+	// The map key this value is, if it is one, for DumpContextFunc.
+	mapKey := s.mapKey
+	s.mapKey = reflect.Value{}
+	if s.recursion > s.config.maxRecursionDepth() {
+		s.writeString("<max recursion exceeded>")
+		s.truncated("MaxRecursionDepth")
 		return
 	}
+	// Values written elsewhere first, like compact forms being tried, are not part of the output yet.
+	if s.regions != nil && s.w == s.regions.w {
+		defer s.recordRegion(value, s.regions.buf.Len())
+	}
 
+	// Values of interface types, such as the elements of []interface{}, are dumped as the values
+	// they hold. Other values are returned as is, which costs no more than checking their kind.
+	// Interfaces in unexported fields are accessed through their address if possible, so that the
+	// methods of the values they hold can be called even if their types are unexported.
+	if value.Kind() == reflect.Interface && !value.CanInterface() {
+		value = exposeUnexported(value)
+	}
 	v := deInterface(value)
+	if s.config.Verbose {
+		v = exposeUnexported(v)
+	}
+	if s.config.Transform != nil {
+		if s.transformed {
+			s.transformed = false
+		} else if replacement, ok := s.config.Transform(v); ok {
+			if !replacement.IsValid() {
+				printNil(s)
+				return
+			}
+			s.transformed = true
+			s.dumpVal(replacement)
+			return
+		}
+	}
+	if s.redacts(v) {
+		s.writeString(redactedText)
+		return
+	}
 	kind := v.Kind()
+	if s.config.ErrorStackTraces && s.recursion != s.skipStackTrace {
+		if pcs, ok := stackTrace(v); ok {
+			// The value pointed to carries the same stack trace if it is an error as well.
+			if kind == reflect.Ptr {
+				s.skipStackTrace = s.recursion + 1
+			}
+			defer func() {
+				s.skipStackTrace = 0
+				s.dumpStackTrace(pcs)
+			}()
+		}
+	}
+	if s.config.AnnotateKinds {
+		defer s.inlineComment("kind=" + kind.String())
+	}
+
+	if kind == reflect.Ptr && v.IsNil() {
+		if s.config.TypedNils {
+			s.writeString("(")
+			s.dumpType(v)
+			s.writeString(")(nil)")
+		} else {
+			s.writeString("nil")
+		}
+		return
+	}
+
+	if s.config.MaxDepth > 0 && s.depth >= s.config.MaxDepth && isCompositeKind(kind) && !isEmptyValue(v) {
+		s.dumpType(v)
+		s.writeString("{...}")
+		s.truncated("MaxDepth")
+		return
+	}
+
+	if s.config.DeduplicateSubtrees && isCompositeKind(kind) && s.dumpDuplicate(v) {
+		return
+	}
+
+	if s.config.CompactThreshold > 0 && !s.config.Compact && isCompositeKind(kind) && s.tryDumpCompact(value) {
+		return
+	}
 
 	// Try to handle with dump func
 	if s.config.DumpFunc != nil {
@@ -343,10 +1870,36 @@ func (s *dumpState) dumpVal(value reflect.Value) {
 			return
 		}
 	}
+	if s.config.DumpContextFunc != nil {
+		buf := new(bytes.Buffer)
+		if s.config.DumpContextFunc(s.dumpContext(mapKey), v, buf) {
+			s.dumpCustom(v, buf)
+			return
+		}
+	}
+
+	// Values of builtin scalar types have no methods and are not handled by the default dumpers,
+	// so they skip the checks below, which matters for records with many scalar fields.
+	hasMethods := !isScalarKind(kind) || v.Type().PkgPath() != ""
+
+	// Methods cannot be called on values obtained through unexported fields, so these are accessed
+	// through their address if possible, and dumped like values without methods otherwise.
+	if hasMethods && !v.CanInterface() {
+		v = exposeUnexported(v)
+	}
 
 	// Handle custom dumpers
-	dumperType := reflect.TypeOf((*Dumper)(nil)).Elem()
-	if v.Type().Implements(dumperType) {
+	customDumper := hasMethods && usesCustomDumper(v)
+	if customDumper && v.Type().Implements(indentedDumperType) {
+		s.descendIntoPossiblePointer(v, func() {
+			buf := new(bytes.Buffer)
+			dumpFunc := v.MethodByName("LitterDumpIndented")
+			dumpFunc.Call([]reflect.Value{reflect.ValueOf(buf), reflect.ValueOf(s.depth)})
+			s.dumpIndentedCustom(v, buf)
+		})
+		return
+	}
+	if customDumper {
 		s.descendIntoPossiblePointer(v, func() {
 			// Run the custom dumper buffering the output
 			buf := new(bytes.Buffer)
@@ -357,39 +1910,69 @@ func (s *dumpState) dumpVal(value reflect.Value) {
 		return
 	}
 
+	if hasMethods && s.config.UseFormatter && v.Type().Implements(formatterType) && v.CanInterface() {
+		s.writeReindented(fmt.Sprintf("%+v", v.Interface()))
+		s.inlineComment(s.typeName(v.Type()))
+		return
+	}
+
+	if hasMethods && s.dumpWithDefaultDumpers(value) {
+		return
+	}
+
+	if s.showScalarType(v) {
+		s.dumpType(v)
+		s.writeString("(")
+		defer s.writeString(")")
+	}
+
 	switch kind {
 	case reflect.Invalid:
 		// Do nothing.  We should never get here since invalid has already
 		// been handled above.
-		s.write([]byte("<invalid>"))
+		s.writeString("<invalid>")
 
 	case reflect.Bool:
-		printBool(s.w, v.Bool())
+		printBool(s, v.Bool())
 
 	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
-		printInt(s.w, v.Int(), 10)
+		if !s.dumpEnum(v, v.Int()) && !s.dumpFlags(v, uint64(v.Int())) {
+			printInt(s, v.Int(), 10)
+			s.groupDigits(strconv.FormatInt(v.Int(), 10))
+		}
 
 	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
-		printUint(s.w, v.Uint(), 10)
+		if !s.dumpEnum(v, int64(v.Uint())) && !s.dumpFlags(v, v.Uint()) {
+			printUint(s, v.Uint(), 10)
+			s.groupDigits(strconv.FormatUint(v.Uint(), 10))
+		}
+
+	case reflect.Uintptr:
+		s.dumpUintptr(v)
 
 	case reflect.Float32:
-		printFloat(s.w, v.Float(), 32)
+		printFloat(s, v.Float(), 32)
 
 	case reflect.Float64:
-		printFloat(s.w, v.Float(), 64)
+		printFloat(s, v.Float(), 64)
 
 	case reflect.Complex64:
-		printComplex(s.w, v.Complex(), 32)
+		printComplex(s, v.Complex(), 32)
 
 	case reflect.Complex128:
-		printComplex(s.w, v.Complex(), 64)
+		printComplex(s, v.Complex(), 64)
 
 	case reflect.String:
-		s.write([]byte(strconv.Quote(v.String())))
+		s.dumpString(v)
 
 	case reflect.Slice:
 		if v.IsNil() {
-			printNil(s.w)
+			if !s.config.BareNilSlices {
+				s.dumpType(v)
+				s.writeString("(nil)")
+			} else {
+				printNil(s)
+			}
 			break
 		}
 		fallthrough
@@ -400,20 +1983,36 @@ func (s *dumpState) dumpVal(value reflect.Value) {
 		})
 
 	case reflect.Interface:
-		// The only time we should get here is for nil interfaces due to
-		// unpackValue calls.
+		// The only time we should get here is for nil interfaces, since
+		// deInterface unwraps all others, including embedded interface fields.
 		if v.IsNil() {
-			printNil(s.w)
+			printNil(s)
 		}
 
 	case reflect.Ptr:
+		if s.config.MaxPointerDepth > 0 && pointerChain >= s.config.MaxPointerDepth {
+			s.dumpPointerAddress(v)
+			s.truncated("MaxPointerDepth")
+			break
+		}
+		if s.config.InlineScalarPointers && !s.config.StrictGo && isScalarKind(v.Elem().Kind()) {
+			s.dumpVal(v.Elem())
+			break
+		}
 		s.descendIntoPossiblePointer(v, func() {
-			if s.config.StrictGo {
+			if literal := s.timeLiteralType(v.Elem()); literal != "" {
+				s.writeString(fmt.Sprintf("(func(v %s) *%s { return &v })(", literal, literal))
+				s.pointerChain = pointerChain + 1
+				s.dumpVal(v.Elem())
+				s.writeString(")")
+			} else if s.config.StrictGo {
 				s.writeString(fmt.Sprintf("(func(v %s) *%s { return &v })(", v.Elem().Type(), v.Elem().Type()))
+				s.pointerChain = pointerChain + 1
 				s.dumpVal(v.Elem())
 				s.writeString(")")
 			} else {
 				s.writeString("&")
+				s.pointerChain = pointerChain + 1
 				s.dumpVal(v.Elem())
 			}
 		})
@@ -425,6 +2024,9 @@ func (s *dumpState) dumpVal(value reflect.Value) {
 
 	case reflect.Struct:
 		s.dumpStruct(v)
+		if s.config.HexDumpStructs != nil && s.isHexDumped(v.Type()) {
+			s.dumpHexDump(v)
+		}
 
 	case reflect.Func:
 		s.dumpFunc(v)
@@ -441,6 +2043,155 @@ func (s *dumpState) dumpVal(value reflect.Value) {
 	}
 }
 
+// errCompactTooLong aborts rendering a value in compact form when it exceeds CompactThreshold.
+var errCompactTooLong = errors.New("compact form exceeds threshold")
+
+// thresholdWriter is a bytes.Buffer that fails once more than max bytes are written to it.
+type thresholdWriter struct {
+	bytes.Buffer
+	max int
+}
+
+func (w *thresholdWriter) Write(b []byte) (int, error) {
+	if w.Len()+len(b) > w.max {
+		return 0, errCompactTooLong
+	}
+	return w.Buffer.Write(b)
+}
+
+func (w *thresholdWriter) WriteString(str string) (int, error) {
+	if w.Len()+len(str) > w.max {
+		return 0, errCompactTooLong
+	}
+	return w.Buffer.WriteString(str)
+}
+
+// errOutputLimit aborts dumping once MaxOutputBytes have been written.
+var errOutputLimit = errors.New("output exceeds MaxOutputBytes")
+
+// limitWriter writes to w until remaining bytes have been written, and fails after writing as much
+// of the data exceeding the limit as fits.
+type limitWriter struct {
+	w         io.Writer
+	remaining int
+}
+
+func (w *limitWriter) Write(b []byte) (int, error) {
+	if len(b) <= w.remaining {
+		n, err := w.w.Write(b)
+		w.remaining -= n
+		return n, err
+	}
+	n, err := w.w.Write(b[:w.remaining])
+	w.remaining -= n
+	if err != nil {
+		return n, err
+	}
+	return n, errOutputLimit
+}
+
+func (w *limitWriter) WriteString(str string) (int, error) {
+	if len(str) <= w.remaining {
+		n, err := io.WriteString(w.w, str)
+		w.remaining -= n
+		return n, err
+	}
+	n, err := io.WriteString(w.w, str[:w.remaining])
+	w.remaining -= n
+	if err != nil {
+		return n, err
+	}
+	return n, errOutputLimit
+}
+
+// tryDumpCompact renders the value in compact form and writes it if it fits within
+// CompactThreshold. Otherwise nothing is written, any changes to the pointer state are rolled back,
+// and false is returned.
+func (s *dumpState) tryDumpCompact(value reflect.Value) (ok bool) {
+	opts := *s.config
+	opts.Compact = true
+	buf := &thresholdWriter{max: s.config.CompactThreshold}
+	probe := *s
+	probe.config = &opts
+	probe.w = buf
+
+	visited := s.visitedPointers.clone()
+	count := s.pointers.count
+	visitedStrings, stringCount := s.strings.snapshot()
+	defer func() {
+		if !ok {
+			s.visitedPointers = visited
+			s.pointers.resetLabels(count)
+			s.strings.rollback(visitedStrings, stringCount)
+		}
+		if r := recover(); r != nil && r != (writeError{errCompactTooLong}) {
+			panic(r)
+		}
+	}()
+
+	probe.dumpVal(value)
+	s.visitedPointers = probe.visitedPointers
+	s.truncations = probe.truncations
+	s.currentPointer = probe.currentPointer
+	s.omitType = probe.omitType
+	s.write(buf.Bytes())
+	return true
+}
+
+// dumpDuplicate replaces the value with a reference to the path it was first dumped at, if an equal
+// value was dumped before, and returns true. Values are equal if their compact dumps are, which
+// makes this expensive. Values containing pointers that are referenced from elsewhere are always
+// dumped, so that the labels of these pointers are not lost.
+func (s *dumpState) dumpDuplicate(v reflect.Value) bool {
+	opts := *s.config
+	opts.Compact = true
+	opts.DeduplicateSubtrees = false
+	opts.CompactThreshold = 0
+	opts.MaxDepth = 0
+	opts.SummarizeAbove = 0
+	opts.MaxMapLength = 0
+	opts.OnTruncate = nil
+	buf := new(bytes.Buffer)
+	newDumpState(v, &opts, buf).dumpVal(v)
+	if buf.Len() < s.config.minSubtreeSize() {
+		return false
+	}
+
+	path := s.pathString()
+	if s.subtrees == nil {
+		s.subtrees = make(map[string]string)
+	}
+	first, ok := s.subtrees[buf.String()]
+	if !ok {
+		s.subtrees[buf.String()] = path
+		return false
+	}
+	// The path is the same when dumping again after trying CompactThreshold.
+	if first == path || s.containsReusedPointer(v) {
+		return false
+	}
+	s.dumpType(v)
+	s.writeString("{...}")
+	s.inlineComment("same as " + first)
+	return true
+}
+
+// containsReusedPointer returns true if the value contains a pointer that is referenced more than
+// once in the dumped value.
+func (s *dumpState) containsReusedPointer(v reflect.Value) bool {
+	pv := &pointerVisitor{
+		maxRecursion: s.config.maxRecursionDepth(),
+		containers:   s.config.dumpsContainers(),
+	}
+	pv.consider(v, 0)
+	for key := range pv.pointers.m {
+		if _, ok := s.pointers.m[key]; ok && key.p != 0 {
+			return true
+		}
+	}
+	return false
+}
+
 // registers that the value has been visited and checks to see if it is one of the
 // pointers we will see multiple times. If it is, it returns a temporary name for this
 // pointer. It also returns a boolean value indicating whether this is the first time
@@ -458,19 +2209,35 @@ func (s *dumpState) pointerFor(v reflect.Value) (*ptrinfo, bool) {
 
 // prepares a new state object for dumping the provided value
 func newDumpState(value reflect.Value, options *Options, writer io.Writer) *dumpState {
+	pointers, strings := mapReusedPointers(value, options)
 	result := &dumpState{
 		config:   options,
-		pointers: mapReusedPointers(value),
+		pointers: pointers,
+		strings:  strings,
 		w:        writer,
 	}
+	result.output, _ = writer.(*limitWriter)
+	if options.ShowSliceSharing {
+		result.backingArrays = new([]backingArray)
+	}
 
 	if options.HomePackage != "" {
-		result.homePackageRegexp = regexp.MustCompile(fmt.Sprintf("\\b%s\\.", options.HomePackage))
+		result.homePackageRegexp = homePackageRegexp(options.HomePackage)
 	}
 
 	return result
 }
 
+// homePackageRegexp returns the regexp matching the given package qualifier in type names,
+// compiling it only the first time.
+func homePackageRegexp(pkg string) *regexp.Regexp {
+	if re, ok := homePackageRegexps.Load(pkg); ok {
+		return re.(*regexp.Regexp)
+	}
+	re, _ := homePackageRegexps.LoadOrStore(pkg, regexp.MustCompile(fmt.Sprintf("\\b%s\\.", pkg)))
+	return re.(*regexp.Regexp)
+}
+
 // Dump a value to stdout.
 func Dump(value ...interface{}) {
 	(&Config).Dump(value...)
@@ -483,37 +2250,155 @@ func D(value ...interface{}) {
 
 // Sdump dumps a value to a string.
 func Sdump(value ...interface{}) string {
-	return (&Config).Sdump(value...)
+	return SdumpWith(&Config, value...)
+}
+
+// Fdump dumps a value to a writer, returning the first error from writing.
+func Fdump(w io.Writer, value ...interface{}) error {
+	return FdumpWith(&Config, w, value...)
+}
+
+// SdumpWith dumps a value to a string according to the options. Unlike Options.Sdump, the options
+// are not copied, which makes a difference when dumping in hot paths.
+func SdumpWith(o *Options, values ...interface{}) string {
+	buf := new(bytes.Buffer)
+	_ = FdumpWith(o, buf, values...)
+	return buf.String()
 }
 
 // Dump a value to stdout according to the options
 func (o Options) Dump(values ...interface{}) {
-	for i, value := range values {
-		state := newDumpState(reflect.ValueOf(value), &o, os.Stdout)
-		if i > 0 {
-			state.write([]byte(o.Separator))
-		}
-		state.dump(value)
-	}
-	_, _ = os.Stdout.Write([]byte("\n"))
+	_ = FdumpWith(&o, os.Stdout, values...)
+	_, _ = io.WriteString(os.Stdout, "\n")
 }
 
 // Sdump dumps a value to a string according to the options
 func (o Options) Sdump(values ...interface{}) string {
-	buf := new(bytes.Buffer)
+	return SdumpWith(&o, values...)
+}
+
+// Fdump dumps a value to a writer according to the options. Any writer can be used, for example a
+// *strings.Builder to append to a larger report. Dumping stops at the first error returned by the
+// writer, and the error is returned.
+func (o Options) Fdump(w io.Writer, values ...interface{}) error {
+	return FdumpWith(&o, w, values...)
+}
+
+// Tee returns a function dumping values to all of the writers at once, like Fdump with an
+// io.MultiWriter, for example to both os.Stdout and a log file. Each write goes to the writers in
+// order, and dumping stops at the first writer returning an error, which is returned: the writers
+// before it have received the failed write, and the writers after it have not, so their output ends
+// earlier.
+func (o Options) Tee(writers ...io.Writer) func(values ...interface{}) error {
+	w := io.MultiWriter(writers...)
+	return func(values ...interface{}) error {
+		return FdumpWith(&o, w, values...)
+	}
+}
+
+// FdumpWith dumps a value to a writer according to the options, like Options.Fdump, but without
+// copying the options.
+func FdumpWith(o *Options, w io.Writer, values ...interface{}) error {
+	return fdump(o, w, nil, nil, values)
+}
+
+// dumpsContainers returns true if the containers of container/list and container/ring are dumped
+// as their elements, which only the default dumpers of the Go format do.
+func (o *Options) dumpsContainers() bool {
+	return o.Format == FormatGo && !o.DisableDefaultDumpers
+}
+
+// comparison returns a copy of the options for dumping values compared when sorting, with the
+// sorting by value turned off: the values may contain the map or slice being sorted, and sorting
+// it again while comparing them would never end.
+func (o *Options) comparison() *Options {
+	c := *o
+	c.SortMapsByValue = false
+	c.SortSlices = false
+	c.CanonicalLabels = false
+	return &c
+}
+
+// verbose returns a copy of the options with the settings hiding content turned off, for Verbose.
+func (o *Options) verbose() *Options {
+	v := *o
+	v.HidePrivateFields = false
+	v.FieldExclusions = nil
+	v.FieldInclusions = nil
+	v.PathExclusions = nil
+	v.HideZeroValues = false
+	v.HideFuncFields = false
+	v.HideEmptyStrings = false
+	v.HideKinds = nil
+	v.DisableDefaultDumpers = true
+	v.DisablePointerReplacement = true
+	v.MaxDepth = 0
+	v.SummarizeAbove = 0
+	v.MaxMapLength = 0
+	v.MaxPointerDepth = 0
+	v.DeduplicateSubtrees = false
+	return &v
+}
+
+// fdump dumps the values, with pointer labels kept consistent with earlier dumps if st is not nil,
+// and the regions of the values recorded if regions is not nil.
+func fdump(o *Options, w io.Writer, st *Stateful, regions *regionRecorder, values []interface{}) (err error) {
+	if o.Verbose {
+		o = o.verbose()
+	}
+	// The values share the output budget, so the writer is not limited per value.
+	var output *limitWriter
+	if o.MaxOutputBytes > 0 {
+		output = &limitWriter{w: w, remaining: o.MaxOutputBytes}
+		w = output
+	}
+	if regions != nil {
+		regions.w = w
+	}
+	var state *dumpState
+	defer func() {
+		if r := recover(); r != nil {
+			if we, ok := r.(writeError); ok && we.err == errOutputLimit {
+				state.reportTruncations()
+				_, err = io.WriteString(output.w, "...")
+				return
+			}
+			if we, ok := r.(writeError); ok {
+				err = we.err
+				return
+			}
+			panic(r)
+		}
+	}()
+
 	for i, value := range values {
+		state = newDumpState(reflect.ValueOf(value), o, w)
+		state.regions = regions
+		if st != nil {
+			st.restore(state)
+		}
+		state.varName = o.VarName
 		if i > 0 {
-			_, _ = buf.Write([]byte(o.Separator))
+			state.writeString(o.Separator)
+			if o.VarName != "" {
+				state.varName += strconv.Itoa(i + 1)
+			}
 		}
-		state := newDumpState(reflect.ValueOf(value), &o, buf)
 		state.dump(value)
+		state.reportTruncations()
+		if st != nil {
+			st.record(state)
+		}
 	}
-	return buf.String()
+	return nil
 }
 
 type mapKeySorter struct {
 	keys    []reflect.Value
 	options *Options
+
+	// m is the sorted map, if keys that dump identically should be ordered by their values.
+	m reflect.Value
 }
 
 func (s mapKeySorter) Len() int {
@@ -524,10 +2409,93 @@ func (s mapKeySorter) Swap(i, j int) {
 	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
 }
 
+// Less orders keys by their dumped form. Keys of interface types are ordered by the name of their
+// dynamic type first, so that keys of different types are grouped together, with nil first, and
+// numbers of the same type by value. Pointer keys are ordered by what they point to if DerefMapKeys
+// is set.
 func (s mapKeySorter) Less(i, j int) bool {
+	keyI, keyJ := s.keys[i], s.keys[j]
+	if s.options.DerefMapKeys {
+		keyI, keyJ = derefPointers(keyI), derefPointers(keyJ)
+	}
+	ki := deInterface(keyI)
+	kj := deInterface(keyJ)
+	if s.keys[i].Kind() == reflect.Interface {
+		if ti, tj := dynamicTypeName(ki), dynamicTypeName(kj); ti != tj {
+			return ti < tj
+		}
+		ni, iok := numericValue(ki)
+		nj, jok := numericValue(kj)
+		if less, ok := numericLess(ni, nj); iok && jok && ok {
+			return less
+		}
+	}
+
 	ibuf := new(bytes.Buffer)
 	jbuf := new(bytes.Buffer)
-	newDumpState(s.keys[i], s.options, ibuf).dumpVal(s.keys[i])
-	newDumpState(s.keys[j], s.options, jbuf).dumpVal(s.keys[j])
+	newDumpState(keyI, s.options, ibuf).dumpVal(keyI)
+	newDumpState(keyJ, s.options, jbuf).dumpVal(keyJ)
+	if ibuf.String() != jbuf.String() || !s.m.IsValid() {
+		return ibuf.String() < jbuf.String()
+	}
+
+	vi := s.m.MapIndex(s.keys[i])
+	vj := s.m.MapIndex(s.keys[j])
+	ibuf.Reset()
+	jbuf.Reset()
+	newDumpState(vi, s.options, ibuf).dumpVal(vi)
+	newDumpState(vj, s.options, jbuf).dumpVal(vj)
 	return ibuf.String() < jbuf.String()
 }
+
+// numericLess orders numbers by value, with NaN after every other number. ok is false if the
+// numbers are equal or both NaN, leaving their order to their dumps.
+func numericLess(a, b float64) (less, ok bool) {
+	switch aNaN, bNaN := math.IsNaN(a), math.IsNaN(b); {
+	case aNaN || bNaN:
+		return bNaN && !aNaN, aNaN != bNaN
+	case a != b:
+		return a < b, true
+	}
+	return false, false
+}
+
+// dynamicTypeName returns the name of the type of a value taken out of an interface, or an empty
+// string for nil.
+func dynamicTypeName(v reflect.Value) string {
+	if v.Kind() == reflect.Interface {
+		return ""
+	}
+	return v.Type().String()
+}
+
+// mapValueSorter sorts map keys by their values, largest first. Numbers are compared by value, and
+// other values by their dumped form.
+type mapValueSorter struct {
+	keys    []reflect.Value
+	m       reflect.Value
+	options *Options
+}
+
+func (s mapValueSorter) Len() int {
+	return len(s.keys)
+}
+
+func (s mapValueSorter) Swap(i, j int) {
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+}
+
+func (s mapValueSorter) Less(i, j int) bool {
+	vi := deInterface(s.m.MapIndex(s.keys[i]))
+	vj := deInterface(s.m.MapIndex(s.keys[j]))
+	if ni, ok := numericValue(vi); ok {
+		if nj, ok := numericValue(vj); ok {
+			return ni > nj
+		}
+	}
+	ibuf := new(bytes.Buffer)
+	jbuf := new(bytes.Buffer)
+	newDumpState(vi, s.options, ibuf).dumpVal(vi)
+	newDumpState(vj, s.options, jbuf).dumpVal(vj)
+	return ibuf.String() > jbuf.String()
+}
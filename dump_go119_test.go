@@ -0,0 +1,25 @@
+//go:build go1.19
+// +build go1.19
+
+package litter_test
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+func TestSdump_atomicTypes(t *testing.T) {
+	type Counters struct {
+		Count   atomic.Int64
+		Ready   atomic.Bool
+		Current atomic.Value
+		Unset   atomic.Value
+		hits    atomic.Int32
+	}
+	counters := &Counters{}
+	counters.Count.Store(42)
+	counters.Ready.Store(true)
+	counters.Current.Store(&BasicStruct{1, 2})
+	counters.hits.Store(7)
+	runTests(t, "atomicTypes", counters)
+}
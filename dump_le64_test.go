@@ -0,0 +1,56 @@
+//go:build amd64 || arm64 || loong64 || mips64le || ppc64le || riscv64 || wasm
+// +build amd64 arm64 loong64 mips64le ppc64le riscv64 wasm
+
+package litter_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sanity-io/litter"
+)
+
+// The dumps of these tests depend on the memory layout of values, which is that of little-endian
+// architectures with 64-bit pointers.
+
+func TestSdump_HexDumpStructs(t *testing.T) {
+	type Header struct {
+		Magic   [4]byte
+		Version uint8
+		Flags   uint8
+		Length  uint16
+		Seq     uint32
+	}
+	type Packet struct {
+		Header  Header
+		Payload string
+	}
+	hexDumped := []reflect.Type{reflect.TypeOf(Header{}), reflect.TypeOf(Packet{})}
+	packet := Packet{Header: Header{Magic: [4]byte{'L', 'T', 'R', 0}, Version: 1, Length: 513, Seq: 7}, Payload: "hi"}
+	runTestWithCfg(t, "config_HexDumpStructs", &litter.Options{
+		HexDumpStructs: hexDumped,
+	}, packet)
+	runTestWithCfg(t, "config_HexDumpStructs_Compact", &litter.Options{
+		HexDumpStructs: hexDumped,
+		Compact:        true,
+	}, &packet)
+}
+
+func TestSdump_ShowSizes(t *testing.T) {
+	type Record struct {
+		ID     int64
+		Name   string
+		Tags   []string
+		Scores map[string]int32
+		Parent *Record
+	}
+	root := &Record{ID: 1, Name: "root"}
+	runTestWithCfg(t, "config_ShowSizes", &litter.Options{
+		ShowSizes:      true,
+		SummarizeAbove: 100,
+	}, []*Record{
+		root,
+		{ID: 2, Name: "child", Tags: make([]string, 2, 4), Scores: map[string]int32{"a": 1}, Parent: root},
+		{ID: 3, Tags: make([]string, 1000), Scores: map[string]int32{}},
+	})
+}
@@ -1,13 +1,30 @@
 package litter_test
 
 import (
+	"bytes"
+	"container/list"
+	"container/ring"
+	"database/sql"
+	"encoding/json"
 	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
 	"io"
 	"io/ioutil"
+	"math"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"reflect"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+	"unsafe"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -26,6 +43,10 @@ type BasicStruct struct {
 	private int
 }
 
+func (s BasicStruct) Sum() int {
+	return s.Public + s.private
+}
+
 type IntAlias int
 
 type InterfaceStruct struct {
@@ -36,6 +57,13 @@ type RecursiveStruct struct {
 	Ptr *RecursiveStruct
 }
 
+func (r *RecursiveStruct) Depth() int {
+	if r.Ptr == nil {
+		return 0
+	}
+	return r.Ptr.Depth() + 1
+}
+
 type CustomMap map[string]int
 
 type CustomMultiLineDumper struct {
@@ -46,12 +74,173 @@ func (cmld *CustomMultiLineDumper) LitterDump(w io.Writer) {
 	_, _ = w.Write([]byte("{\n  multi\n  line\n}"))
 }
 
+type CustomIndentedDumper struct{}
+
+func (cid CustomIndentedDumper) LitterDump(w io.Writer) {
+	_, _ = w.Write([]byte("    {\n      indented\n\n        more\n    }"))
+}
+
+type CustomNestingDumper struct {
+	Inner interface{}
+}
+
+func (cnd CustomNestingDumper) LitterDumpIndented(w io.Writer, depth int) {
+	indent := strings.Repeat("  ", depth+1)
+	inner := strings.ReplaceAll(litter.Sdump(cnd.Inner), "\n", "\n"+indent)
+	_, _ = fmt.Fprintf(w, "{\n%sInner: %s,\n%s}", indent, inner, strings.Repeat("  ", depth))
+}
+
+type OptionalDumper struct {
+	Amount int
+}
+
+func (od OptionalDumper) LitterDump(w io.Writer) {
+	_, _ = fmt.Fprintf(w, "<%d>", od.Amount)
+}
+
+func (od OptionalDumper) LitterShouldDump() bool {
+	return od.Amount != 0
+}
+
+type Money int
+
+func (m Money) Format(f fmt.State, verb rune) {
+	if f.Flag('+') {
+		_, _ = fmt.Fprintf(f, "USD %d.%02d", int(m)/100, int(m)%100)
+		return
+	}
+	_, _ = fmt.Fprintf(f, "$%d.%02d", int(m)/100, int(m)%100)
+}
+
+// Invoice formats as one line per amount.
+type Invoice []Money
+
+func (inv Invoice) Format(f fmt.State, verb rune) {
+	_, _ = fmt.Fprint(f, "Invoice{")
+	for _, m := range inv {
+		_, _ = fmt.Fprintf(f, "\n  %+v", m)
+	}
+	_, _ = fmt.Fprint(f, "\n}")
+}
+
 type CustomSingleLineDumper int
 
 func (csld CustomSingleLineDumper) LitterDump(w io.Writer) {
 	_, _ = w.Write([]byte("<custom>"))
 }
 
+// ProtoStatus and ProtoUser mimic code generated by protoc-gen-go.
+type ProtoStatus int32
+
+func (x ProtoStatus) String() string {
+	return map[ProtoStatus]string{0: "UNKNOWN", 1: "ACTIVE"}[x]
+}
+
+func (ProtoStatus) EnumDescriptor() ([]byte, []int) {
+	return nil, []int{0}
+}
+
+type ProtoUser struct {
+	Name                 string
+	Status               ProtoStatus
+	XXX_NoUnkeyedLiteral struct{}
+	XXX_sizecache        int32
+}
+
+func (m *ProtoUser) ProtoMessage() {}
+
+func (m *ProtoUser) String() string {
+	return fmt.Sprintf("name:%q  status:%s", m.Name, m.Status)
+}
+
+type Credentials struct {
+	Username string
+	Password string
+}
+
+type Account struct {
+	Password    string
+	Credentials Credentials
+	Backups     []Credentials
+}
+
+type Service struct {
+	Name      string
+	OnStart   func()
+	OnStop    func() error
+	Instances int
+}
+
+type Status int
+
+type Priority uint8
+
+type Tree struct {
+	Value    int
+	Children []*Tree
+}
+
+func buildTree(depth, width int) *Tree {
+	tree := &Tree{Value: depth}
+	if depth > 0 {
+		for i := 0; i < width; i++ {
+			tree.Children = append(tree.Children, buildTree(depth-1, width))
+		}
+	}
+	return tree
+}
+
+type Namer interface {
+	Name() string
+}
+
+type StaticNamer struct {
+	Value string
+}
+
+func (n StaticNamer) Name() string {
+	return n.Value
+}
+
+type EmbeddedInterface struct {
+	Namer
+	ID int
+}
+
+type ReverseOrderedMap map[int]string
+
+func (m ReverseOrderedMap) LitterKeys() []interface{} {
+	// 1.9 is not an int, so it does not move 1 to the front.
+	return []interface{}{1.9, 3, 2, 1, 42}
+}
+
+// MismatchedOrderedMap returns keys of other types, convertible to its keys but not equal to them.
+type MismatchedOrderedMap map[string]int
+
+func (m MismatchedOrderedMap) LitterKeys() []interface{} {
+	return []interface{}{'Z', "b", 2.9, 2}
+}
+
+func TestSdump_nilFuncsAndChans(t *testing.T) {
+	data := []interface{}{
+		Service{Name: "service"},
+		struct {
+			Events  chan string
+			Sends   chan<- int
+			Handler interface{}
+		}{},
+		(func(string) error)(nil),
+		(<-chan int)(nil),
+	}
+	runTests(t, "nilFuncsAndChans", data)
+	runTestWithCfg(t, "nilFuncsAndChans_Compact", &litter.Options{
+		Compact:                   true,
+		StripPackageNames:         true,
+		ShowFuncLocation:          true,
+		DisablePointerReplacement: true,
+	}, data)
+}
+
 func TestSdump_primitives(t *testing.T) {
 	messages := make(chan string, 3)
 	sends := make(chan<- int64, 1)
@@ -97,6 +286,27 @@ func TestSdump_primitives(t *testing.T) {
 	})
 }
 
+func TestSdump_complex(t *testing.T) {
+	runTests(t, "complex", []interface{}{
+		complex64(complex(1.1, 2.2)),
+		complex64(complex(0.1, -0.3)),
+		complex128(complex(1.1, 2.2)),
+		complex128(complex(0.1, -0.3)),
+		complex64(complex(math.NaN(), 1)),
+		complex64(complex(1, math.Inf(1))),
+		complex128(complex(math.Inf(-1), math.NaN())),
+		complex128(complex(0, math.Inf(-1))),
+		complex(1, math.Copysign(0, -1)),
+	})
+
+	// The components of complex64 values are printed as float32, so they parse back unchanged.
+	third := complex64(complex(1.0/3, -2.0/3))
+	assert.Equal(t, "complex64(0.33333334-0.6666667i)", litter.Sdump(third))
+	parsed, err := strconv.ParseFloat("0.33333334", 32)
+	require.NoError(t, err)
+	assert.Equal(t, real(third), float32(parsed))
+}
+
 func TestSdump_customDumper(t *testing.T) {
 	cmld := CustomMultiLineDumper{Dummy: 1}
 	cmld2 := CustomMultiLineDumper{Dummy: 2}
@@ -113,6 +323,90 @@ func TestSdump_customDumper(t *testing.T) {
 	})
 }
 
+func TestSdump_customDumperIndented(t *testing.T) {
+	runTests(t, "customDumperIndented", []interface{}{
+		CustomIndentedDumper{},
+		map[string]CustomIndentedDumper{"a": {}},
+	})
+}
+
+func TestSdump_customDumperNesting(t *testing.T) {
+	nesting := &CustomNestingDumper{Inner: []int{1, 2}}
+	runTests(t, "customDumperNesting", map[string]interface{}{
+		"nesting": []interface{}{nesting, nesting},
+		"single":  CustomNestingDumper{Inner: "x"},
+	})
+}
+
+func TestSdump_conditionalDumper(t *testing.T) {
+	runTests(t, "customDumperConditional", []interface{}{
+		OptionalDumper{},
+		OptionalDumper{Amount: 3},
+		&OptionalDumper{},
+		&OptionalDumper{Amount: 4},
+		(*OptionalDumper)(nil),
+	})
+}
+
+type secretShape struct {
+	radius float64
+}
+
+func (s secretShape) String() string {
+	return "shape"
+}
+
+type secretDumper struct {
+	label string
+}
+
+func (s secretDumper) LitterDump(w io.Writer) {
+	_, _ = fmt.Fprintf(w, "<%s>", s.label)
+}
+
+func TestSdump_unexportedDynamicTypes(t *testing.T) {
+	type holder struct {
+		shape     interface{}
+		shapes    []fmt.Stringer
+		dumper    litter.Dumper
+		nilDumper litter.Dumper
+		custom    CustomSingleLineDumper
+		Public    litter.Dumper
+	}
+	h := holder{
+		shape:  secretShape{radius: 2},
+		shapes: []fmt.Stringer{&secretShape{radius: 1}},
+		dumper: secretDumper{label: "private"},
+		custom: 4,
+		Public: &secretDumper{label: "public"},
+	}
+	runTests(t, "unexportedDynamicTypes", []interface{}{h, &h})
+	runTestWithCfg(t, "unexportedDynamicTypes_YAML", &litter.Options{
+		Format: litter.FormatYAML,
+	}, h)
+}
+
+func TestSdump_syncTypes(t *testing.T) {
+	type Worker struct {
+		Started sync.Once
+		Pending sync.WaitGroup
+		Lock    sync.Mutex
+		mu      sync.Mutex
+		once    sync.Once
+	}
+	w := &Worker{}
+	w.Started.Do(func() {})
+	w.Pending.Add(2)
+	defer w.Pending.Add(-2)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	runTests(t, "syncTypes", w)
+	runTestWithCfg(t, "syncTypes_Compact", &litter.Options{
+		Compact: true,
+	}, w)
+}
+
 func TestSdump_pointerAliasing(t *testing.T) {
 	p0 := &RecursiveStruct{Ptr: nil}
 	p1 := &RecursiveStruct{Ptr: p0}
@@ -127,6 +421,14 @@ func TestSdump_pointerAliasing(t *testing.T) {
 	})
 }
 
+func TestSdump_slicesSharingFirstElement(t *testing.T) {
+	// Slices starting at the same element hold different values if their lengths differ, so the
+	// longer one must not be replaced by the label of the shorter one.
+	all := []int{1, 2, 3}
+	assert.Equal(t, "[]interface{}{[]int{1},[]int{1,2,3}}",
+		litter.Options{Compact: true}.Sdump([]interface{}{all[:1], all}))
+}
+
 func TestSdump_nilIntefacesInStructs(t *testing.T) {
 	p0 := &InterfaceStruct{nil}
 	p1 := &InterfaceStruct{p0}
@@ -139,6 +441,25 @@ func TestSdump_nilIntefacesInStructs(t *testing.T) {
 	})
 }
 
+func TestSdump_mutuallyReferencingMaps(t *testing.T) {
+	outer := map[string]interface{}{"name": "outer"}
+	inner := map[string]interface{}{"name": "inner", "outer": outer}
+	outer["inner"] = inner
+
+	runTests(t, "mutuallyReferencingMaps", outer)
+	runTestWithCfg(t, "mutuallyReferencingMaps_DisablePointerReplacement", &litter.Options{
+		DisablePointerReplacement: true,
+	}, []interface{}{outer, inner})
+}
+
+func TestSdump_embeddedInterfaces(t *testing.T) {
+	runTests(t, "embeddedInterfaces", []interface{}{
+		EmbeddedInterface{Namer: StaticNamer{Value: "static"}, ID: 1},
+		EmbeddedInterface{Namer: &StaticNamer{Value: "pointer"}, ID: 2},
+		EmbeddedInterface{ID: 3},
+	})
+}
+
 func TestSdump_config(t *testing.T) {
 	type options struct {
 		Compact           bool
@@ -174,6 +495,13 @@ func TestSdump_config(t *testing.T) {
 	runTestWithCfg(t, "config_HideZeroValues", &litter.Options{
 		HideZeroValues: true,
 	}, data)
+	runTestWithCfg(t, "config_HideZeroValues_maps", &litter.Options{
+		HideZeroValues: true,
+	}, []interface{}{
+		map[string]int{"a": 0, "b": 2, "c": 0},
+		map[string]interface{}{"nil": nil, "empty": "", "set": "x"},
+		map[int]int{0: 0},
+	})
 	runTestWithCfg(t, "config_StripPackageNames", &litter.Options{
 		StripPackageNames: true,
 	}, data)
@@ -185,6 +513,10 @@ func TestSdump_config(t *testing.T) {
 			return f.Type.Kind() == reflect.String
 		},
 	}, data)
+	runTestWithCfg(t, "config_FieldInclusions", &litter.Options{
+		FieldInclusions: regexp.MustCompile(`^(Compact|Separator|Public|private)$`),
+		FieldExclusions: regexp.MustCompile(`^private$`),
+	}, data)
 	runTestWithCfg(t, "config_StrictGo", &litter.Options{
 		StrictGo: true,
 	}, data)
@@ -204,6 +536,472 @@ func TestSdump_config(t *testing.T) {
 			return false
 		},
 	}, data)
+	dumpContextFunc := func(ctx litter.DumpContext, v reflect.Value, w io.Writer) bool {
+		if v.Kind() == reflect.String {
+			fmt.Fprintf(w, "(%q /* depth=%d path=%s key=%t */)", v.String(), ctx.Depth, ctx.Path, ctx.MapKey)
+			return true
+		}
+		return false
+	}
+	runTestWithCfg(t, "config_DumpContextFunc", &litter.Options{
+		DumpContextFunc: dumpContextFunc,
+	}, map[string][]Credentials{"users": {{"user", "secret"}}})
+	runTestWithCfg(t, "config_DumpContextFunc_YAML", &litter.Options{
+		DumpContextFunc: dumpContextFunc,
+		Format:          litter.FormatYAML,
+	}, map[string][]Credentials{"users": {{"user", "secret"}}})
+	runTestWithCfg(t, "config_MapKeyDumpFunc", &litter.Options{
+		MapKeyDumpFunc: func(v reflect.Value, w io.Writer) bool {
+			if status, ok := v.Interface().(Status); ok && status == 2 {
+				io.WriteString(w, "(2 /* Active */)")
+				return true
+			}
+			return false
+		},
+	}, map[Status]Status{1: 1, 2: 2})
+	ten, two := 10, 2
+	runTestWithCfg(t, "config_DerefMapKeys", &litter.Options{
+		DerefMapKeys: true,
+	}, []interface{}{
+		map[*int]string{&ten: "ten", &two: "two", nil: "nil"},
+		map[*BasicStruct]bool{{2, 0}: true, {1, 0}: false},
+	})
+
+	runTestWithCfg(t, "config_ShowPrivateFor", &litter.Options{
+		HidePrivateFields: true,
+		ShowPrivateFor:    []reflect.Type{reflect.TypeOf(BasicStruct{})},
+	}, []interface{}{
+		BasicStruct{1, 2},
+		struct {
+			Public  int
+			private int
+		}{3, 4},
+	})
+	runTestWithCfg(t, "config_HideFuncFields", &litter.Options{
+		HideFuncFields: true,
+	}, Service{
+		Name:      "service",
+		OnStart:   func() {},
+		Instances: 2,
+	})
+	runTestWithCfg(t, "config_HideKinds", &litter.Options{
+		HideKinds: []reflect.Kind{reflect.Func, reflect.Chan},
+	}, struct {
+		Service
+		Events  chan string
+		Handler interface{}
+		Label   interface{}
+	}{
+		Service: Service{Name: "service", OnStart: func() {}, Instances: 2},
+		Events:  make(chan string),
+		Handler: func() {},
+		Label:   "label",
+	})
+	runTestWithCfg(t, "config_EnumNames", &litter.Options{
+		EnumNames: map[reflect.Type]map[int64]string{
+			reflect.TypeOf(Status(0)):   {0: "Inactive", 1: "Active"},
+			reflect.TypeOf(Priority(0)): {1: "Low", 2: "High"},
+		},
+	}, []interface{}{Status(0), Status(1), Status(7), Priority(2), IntAlias(1)})
+	layout := struct {
+		Flag  bool
+		Count int64
+		Name  string
+		Small int8
+	}{true, 1, "x", 2}
+	runTestWithCfg(t, "config_ShowFieldLayout", &litter.Options{
+		ShowFieldLayout: true,
+	}, layout)
+	runTestWithCfg(t, "config_ShowFieldLayout_Compact", &litter.Options{
+		Compact:         true,
+		ShowFieldLayout: true,
+	}, layout)
+	runTestWithCfg(t, "config_HideTopLevelType", &litter.Options{
+		HideTopLevelType: true,
+		Separator:        "\n",
+	}, data, &BasicStruct{1, 2}, map[string]BlankStruct{"a": {}}, CustomMap(nil), []int(nil), 42)
+	cyclicByValue := map[string]interface{}{"b": 1}
+	cyclicByValue["a"] = cyclicByValue
+	runTestWithCfg(t, "config_SortMapsByValue", &litter.Options{
+		SortMapsByValue: true,
+	}, []interface{}{
+		map[string]int{"a": 3, "b": 12, "c": 3, "d": 100, "e": 0},
+		map[int]string{1: "b", 2: "c", 3: "a"},
+		cyclicByValue,
+	})
+	runTestWithCfg(t, "config_SummarizeAbove", &litter.Options{
+		SummarizeAbove: 3,
+	}, []interface{}{
+		[]int{1, 2, 3},
+		[4]string{"a", "b", "c", "d"},
+		map[string]int{"a": 1, "b": 2, "c": 3, "d": 4},
+	})
+	active, name, count := true, "optional", 0
+	runTestWithCfg(t, "config_InlineScalarPointers", &litter.Options{
+		InlineScalarPointers: true,
+	}, struct {
+		Active *bool
+		Name   *string
+		Count  *int
+		Unset  *int
+		Basic  *BasicStruct
+	}{&active, &name, &count, nil, &BasicStruct{1, 2}})
+	runTestWithCfg(t, "config_MaskStrings", &litter.Options{
+		MaskStrings: true,
+	}, map[string]string{"email": "user@example.com"})
+	runTestWithCfg(t, "config_maskTag", &litter.Options{}, struct {
+		Name   string
+		Email  string   `litter:"mask"`
+		Phones []string `litter:"mask"`
+	}{"User", "user@example.com", []string{"555-0100", "555-01"}})
+	runTestWithCfg(t, "config_PositionalStructs", &litter.Options{
+		PositionalStructs: true,
+		Compact:           true,
+		HideZeroValues:    true,
+		Separator:         "\n",
+	}, &BasicStruct{1, 2}, Credentials{Username: "user"}, []Credentials{{"user", "secret"}})
+	runTestWithCfg(t, "config_GroupDigits", &litter.Options{
+		GroupDigits: true,
+	}, []interface{}{0, 999, 1000, -123456, int64(-1000000), uint64(18446744073709551615), 1234567.5})
+	type Handle uintptr
+	runTestWithCfg(t, "config_UintptrNames", &litter.Options{
+		UintptrNames: func(p uintptr) (string, bool) {
+			if p == 0x1234 {
+				return `window "main"`, true
+			}
+			return "", false
+		},
+	}, []interface{}{Handle(0x1234), uintptr(0x1234), Handle(0xbeef)})
+	type Temperature float64
+	type Name string
+	runTestWithCfg(t, "config_ShowScalarTypes", &litter.Options{
+		ShowScalarTypes: true,
+		EnumNames:       map[reflect.Type]map[int64]string{reflect.TypeOf(Status(0)): {1: "Inactive"}},
+	}, []interface{}{Temperature(98.6), Name("Bob"), IntAlias(10), Status(1), 42, "plain"})
+	type Row struct {
+		Name   string
+		Age    int
+		Active bool
+	}
+	runTestWithCfg(t, "config_TabularSlices", &litter.Options{
+		TabularSlices:  true,
+		HideZeroValues: true,
+	}, []interface{}{
+		[]Row{{"Bob", 20, true}, {"Alice", 7, false}, {"", 100, true}, {}},
+		[2]Credentials{{"root", "secret"}, {"guest", "guest"}},
+		[]Account{{Password: "not tabular"}},
+	})
+	accounts := []Account{
+		{Credentials: Credentials{"a", "b"}, Backups: []Credentials{{"c", "d"}}},
+		{Credentials: Credentials{"e", "f"}},
+	}
+	runTestWithCfg(t, "config_AbbreviateTypes", &litter.Options{
+		AbbreviateTypes: true,
+		HideZeroValues:  true,
+		Separator:       "\n",
+	}, accounts, &BasicStruct{1, 2})
+	runTestWithCfg(t, "config_AbbreviateTypes_Compact", &litter.Options{
+		AbbreviateTypes: true,
+		HideZeroValues:  true,
+		Compact:         true,
+	}, accounts)
+	runTestWithCfg(t, "config_ProtoText", &litter.Options{
+		ProtoText: true,
+	}, []interface{}{
+		&ProtoUser{Name: "bob", Status: 1},
+		&ProtoUser{},
+		(*ProtoUser)(nil),
+		ProtoStatus(0),
+	})
+	runTestWithCfg(t, "config_ProtoText_StrictGo", &litter.Options{
+		ProtoText: true,
+		StrictGo:  true,
+	}, &ProtoUser{Name: "bob", Status: 1})
+	nils := []interface{}{
+		(*int)(nil),
+		(*BasicStruct)(nil),
+		&RecursiveStruct{},
+		nil,
+	}
+	runTestWithCfg(t, "config_nilPointersInInterfaces", &litter.Options{}, nils)
+	runTestWithCfg(t, "config_TypedNils", &litter.Options{
+		TypedNils: true,
+		StrictGo:  true,
+	}, nils)
+	runTestWithCfg(t, "config_Transform", &litter.Options{
+		Transform: func(v reflect.Value) (reflect.Value, bool) {
+			switch {
+			case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 && v.Len() > 4:
+				return v.Slice(0, 4), true
+			case v.Type() == reflect.TypeOf(time.Time{}):
+				return reflect.ValueOf(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)), true
+			case v.Kind() == reflect.String && v.String() == "secret":
+				return reflect.Value{}, true
+			}
+			return v, false
+		},
+	}, []interface{}{
+		[]byte("truncated"),
+		time.Now(),
+		"secret",
+		"public",
+	})
+	runTestWithCfg(t, "config_Gofmt", &litter.Options{
+		Gofmt:     true,
+		StrictGo:  true,
+		Separator: "\n",
+	}, Account{
+		Password:    "top",
+		Credentials: Credentials{Username: "user"},
+		Backups:     []Credentials{{Username: "backup"}},
+	}, []interface{}{1, (func(v int) *int { return &v })(2)})
+	runTestWithCfg(t, "config_VarName", &litter.Options{
+		VarName:   "expected",
+		Gofmt:     true,
+		StrictGo:  true,
+		Separator: "\n",
+	}, Credentials{Username: "user"}, nil)
+	runTestWithCfg(t, "config_Gofmt_unparsable", &litter.Options{
+		Gofmt:    true,
+		MaxDepth: 1,
+	}, []Credentials{{Username: "user"}})
+	runTestWithCfg(t, "config_AnnotateKinds", &litter.Options{
+		AnnotateKinds: true,
+	}, []interface{}{
+		&BasicStruct{1, 2},
+		map[string]bool{"a": true},
+		(*int)(nil),
+		"string",
+	})
+	runTestWithCfg(t, "config_InlineScalarStructs", &litter.Options{
+		InlineScalarStructs: true,
+	}, []interface{}{
+		&BasicStruct{1, 2},
+		InterfaceStruct{"scalar"},
+		InterfaceStruct{[]int{1}},
+		Account{Password: "top", Credentials: Credentials{"user", "secret"}},
+		BlankStruct{},
+	})
+	type Perm uint8
+	runTestWithCfg(t, "config_FlagNames", &litter.Options{
+		FlagNames: map[reflect.Type]map[uint64]string{
+			reflect.TypeOf(Perm(0)): {1: "Read", 2: "Write", 4: "Execute", 3: "ReadWrite"},
+		},
+	}, []Perm{0, 1, 6, 7, 9, 16})
+	runTestWithCfg(t, "config_ShowMethods", &litter.Options{
+		ShowMethods:       true,
+		HidePrivateFields: true,
+	}, []interface{}{&BasicStruct{1, 2}, RecursiveStruct{}, &ProtoUser{Name: "bob"}, BlankStruct{}})
+	runTestWithCfg(t, "config_ShowMethods_Compact", &litter.Options{
+		ShowMethods: true,
+		Compact:     true,
+	}, BasicStruct{1, 2})
+	runTestWithCfg(t, "config_HideEmptyStrings", &litter.Options{
+		HideEmptyStrings: true,
+	}, struct {
+		Name     string
+		Nickname string
+		Count    int
+		Active   bool
+		Note     interface{}
+	}{Name: "name", Note: ""})
+	large := map[int]string{}
+	for i := 0; i < 10; i++ {
+		large[i] = fmt.Sprint(i)
+	}
+	runTestWithCfg(t, "config_MaxMapLength", &litter.Options{
+		MaxMapLength: 3,
+	}, []interface{}{large, map[int]string{1: "1", 2: "2", 3: "3"}})
+	runTestWithCfg(t, "config_MaxMapLength_Compact", &litter.Options{
+		MaxMapLength: 3,
+		Compact:      true,
+	}, large)
+	runTestWithCfg(t, "config_UseFormatter", &litter.Options{
+		UseFormatter: true,
+	}, struct {
+		Price    Money
+		Prices   []Money
+		Default  *Money
+		Invoices []Invoice
+	}{Price: 150, Prices: []Money{5, 1000}, Invoices: []Invoice{{150, 5}}})
+	verboseShared := &BasicStruct{1, 2}
+	verboseCircular := &RecursiveStruct{}
+	verboseCircular.Ptr = verboseCircular
+	verboseInt := 5
+	verbosePointer := &verboseInt
+	verbosePointers := &verbosePointer
+	runTestWithCfg(t, "config_Verbose", &litter.Options{
+		Verbose:             true,
+		UseFormatter:        true,
+		HidePrivateFields:   true,
+		HideZeroValues:      true,
+		MaxDepth:            1,
+		FieldInclusions:     regexp.MustCompile("^Count$"),
+		PathExclusions:      []string{"copies"},
+		DeduplicateSubtrees: true,
+		MinSubtreeSize:      1,
+		MaxPointerDepth:     1,
+	}, struct {
+		Count    int
+		dumper   CustomSingleLineDumper
+		price    Money
+		at       time.Time
+		shared   []*BasicStruct
+		circular *RecursiveStruct
+		copies   []BasicStruct
+		pointers **int
+	}{
+		dumper:   1,
+		price:    150,
+		shared:   []*BasicStruct{verboseShared, verboseShared},
+		circular: verboseCircular,
+		copies:   []BasicStruct{{3, 4}, {3, 4}},
+		pointers: verbosePointers,
+	})
+	runTestWithCfg(t, "config_BareNilSlices", &litter.Options{
+		BareNilSlices: true,
+	}, struct {
+		Tags  []string
+		Empty []string
+	}{Empty: []string{}})
+	runTestWithCfg(t, "config_MapsAsPairs", &litter.Options{
+		MapsAsPairs: true,
+	}, []interface{}{
+		map[string]int{"b": 2, "a": 1},
+		map[int]*BasicStruct{1: {1, 2}},
+		map[string]bool(nil),
+		map[string]bool{},
+	})
+	runTestWithCfg(t, "config_MapsAsPairs_Compact", &litter.Options{
+		MapsAsPairs: true,
+		Compact:     true,
+	}, map[string]int{"b": 2, "a": 1})
+	type Secret string
+	type Login struct {
+		User   string
+		Token  Secret
+		Backup *Secret
+		Extra  interface{}
+	}
+	backup := Secret("backup")
+	runTestWithCfg(t, "config_RedactTypes", &litter.Options{
+		RedactTypes: []reflect.Type{reflect.TypeOf(Secret(""))},
+	}, map[string][]Login{
+		"logins": {
+			{User: "bob", Token: "token", Backup: &backup, Extra: map[Secret]Secret{"key": "value"}},
+			{User: "alice", Extra: []Secret{"a", "b"}},
+		},
+	})
+	type Directory struct {
+		Accounts map[string]*Account
+		Services [2]Service
+		Root     *Tree
+		Created  time.Time
+		Extra    interface{}
+		hidden   struct{ Key string }
+	}
+	runTestWithCfg(t, "config_SchemaOnly", &litter.Options{
+		SchemaOnly: true,
+	}, Directory{})
+	runTestWithCfg(t, "config_SchemaOnly_Compact", &litter.Options{
+		SchemaOnly:     true,
+		Compact:        true,
+		HideFuncFields: true,
+	}, []*RecursiveStruct{})
+	shared := &BasicStruct{1, 2}
+	other := &BasicStruct{3, 4}
+	preambleCircular := &RecursiveStruct{}
+	preambleCircular.Ptr = preambleCircular
+	runTestWithCfg(t, "config_PointerPreamble", &litter.Options{
+		PointerPreamble: true,
+	}, map[string]interface{}{
+		"a": shared,
+		"b": []*BasicStruct{other, shared},
+		"c": other,
+		"d": preambleCircular,
+		"e": preambleCircular,
+		"f": &BasicStruct{5, 6},
+	})
+	runTestWithCfg(t, "config_PointerPreamble_Compact", &litter.Options{
+		PointerPreamble: true,
+		Compact:         true,
+	}, map[string]*BasicStruct{"x": shared, "y": shared})
+	cyclicSlice := []interface{}{2, nil}
+	cyclicSlice[1] = cyclicSlice
+	runTestWithCfg(t, "config_SortSlices", &litter.Options{
+		SortSlices: true,
+		Compact:    true,
+		Separator:  "\n",
+	}, []int{3, 1, 2}, []interface{}{2.5, 1, uint8(2)}, []string{"b", "c", "a"},
+		[]*BasicStruct{{2, 1}, {1, 2}, {1, 1}}, []interface{}{"b", 1, nil, true}, [3]int{3, 1, 2}, cyclicSlice)
+	type Label string
+	runTestWithCfg(t, "config_UnquoteIdentKeys", &litter.Options{
+		UnquoteIdentKeys: true,
+	}, map[string]interface{}{
+		"name":      "bob",
+		"_id2":      1,
+		"full name": "Bob Smith",
+		"2fa":       true,
+		"":          nil,
+		"nested":    map[Label]int{"x_y": 1, "x-y": 2},
+		"keys":      map[interface{}]int{"a": 1, 2: 2},
+	})
+	backing := [6]int{1, 2, 3, 4, 5, 6}
+	separate := []int{1, 2}
+	runTestWithCfg(t, "config_ShowSliceSharing", &litter.Options{
+		ShowSliceSharing: true,
+	}, map[string][]int{
+		"a_head":     backing[0:3],
+		"b_tail":     backing[3:6],
+		"c_overlap":  backing[2:4],
+		"d_capped":   backing[0:1:1],
+		"e_separate": separate,
+		"f_empty":    backing[6:],
+	})
+	runTestWithCfg(t, "config_ShowSliceSharing_Compact", &litter.Options{
+		ShowSliceSharing: true,
+		Compact:          true,
+	}, [][]int{backing[1:3], backing[:1:1], backing[2:]})
+	type Matrix struct {
+		Rows [2][3]float64
+		ID   [16]byte
+		Mask []bool
+		Tags []string
+		Big  []int
+	}
+	runTestWithCfg(t, "config_ScalarArrayInline", &litter.Options{
+		ScalarArrayInline: 16,
+	}, &Matrix{
+		Rows: [2][3]float64{{1, 0, 0}, {0, 1.5, 0}},
+		ID:   [16]byte{0xde, 0xad, 0xbe, 0xef},
+		Mask: []bool{true, false},
+		Tags: []string{"a"},
+		Big:  make([]int, 17),
+	})
+	runTestWithCfg(t, "config_TypeNameFunc", &litter.Options{
+		TypeNameFunc: func(t reflect.Type) string {
+			if t.Name() != "" {
+				return strings.ToUpper(t.Name())
+			}
+			return "<" + t.Kind().String() + ">"
+		},
+	}, []interface{}{
+		&BasicStruct{1, 2},
+		map[string]IntAlias{"a": 1},
+		[]*Tree{{Value: 1}},
+		json.Number("1.5"),
+		time.Time{},
+	})
+	runTestWithCfg(t, "config_PathExclusions", &litter.Options{
+		PathExclusions: []string{"Credentials.Password", "Backups[1].Password"},
+	}, Account{
+		Password:    "top",
+		Credentials: Credentials{Username: "user", Password: "secret"},
+		Backups: []Credentials{
+			{Username: "backup0", Password: "secret0"},
+			{Username: "backup1", Password: "secret1"},
+		},
+	})
 
 	basic := &BasicStruct{1, 2}
 	runTestWithCfg(t, "config_DisablePointerReplacement_simpleReusedStruct", &litter.Options{
@@ -214,6 +1012,517 @@ func TestSdump_config(t *testing.T) {
 	runTestWithCfg(t, "config_DisablePointerReplacement_circular", &litter.Options{
 		DisablePointerReplacement: true,
 	}, circular)
+	runTestWithCfg(t, "config_PointerLabelPrefix", &litter.Options{
+		PointerLabelPrefix: "ref",
+	}, []interface{}{basic, basic, circular})
+	runTestWithCfg(t, "config_ExplainElisions", &litter.Options{
+		ExplainElisions: true,
+	}, []interface{}{basic, basic, circular})
+	runTestWithCfg(t, "config_ExplainElisions_DisablePointerReplacement", &litter.Options{
+		ExplainElisions:           true,
+		DisablePointerReplacement: true,
+	}, []interface{}{basic, basic, circular})
+}
+
+func Example_mapKeyDumpFunc() {
+	names := map[Status]string{1: "Inactive", 2: "Active"}
+	opts := litter.Options{
+		StripPackageNames: true,
+		MapKeyDumpFunc: func(v reflect.Value, w io.Writer) bool {
+			if v.Type() != reflect.TypeOf(Status(0)) {
+				return false
+			}
+			fmt.Fprintf(w, "(%d /* %s */)", v.Int(), names[Status(v.Int())])
+			return true
+		},
+	}
+	fmt.Println(opts.Sdump(map[Status]int{1: 3, 2: 5}))
+	// Output:
+	// map[Status]int{
+	//   Status(1 /* Inactive */): 3,
+	//   Status(2 /* Active */): 5,
+	// }
+}
+
+func TestSdump_methods(t *testing.T) {
+	basic := BasicStruct{1, 2}
+	recursive := &RecursiveStruct{}
+	var w io.Writer = new(bytes.Buffer)
+	runTests(t, "methods", []interface{}{
+		basic.Sum,
+		recursive.Depth,
+		BasicStruct.Sum,
+		(*RecursiveStruct).Depth,
+		struct {
+			OnSum     func() int
+			OnWrite   func([]byte) (int, error)
+			Anonymous func() int
+		}{basic.Sum, w.Write, func() int { return 0 }},
+	})
+	runTestWithCfg(t, "methods_StripPackageNames", &litter.Options{
+		StripPackageNames: true,
+		Separator:         "\n",
+	}, basic.Sum, recursive.Depth)
+}
+
+func TestSdump_CanonicalLabels(t *testing.T) {
+	build := func() map[*Credentials][]*BasicStruct {
+		shared := &BasicStruct{1, 2}
+		return map[*Credentials][]*BasicStruct{
+			{"user", "secret"}: {shared},
+			{"user", "secret"}: {{3, 4}, shared},
+		}
+	}
+
+	cfg := &litter.Options{CanonicalLabels: true}
+	runTestWithCfg(t, "CanonicalLabels", cfg, build())
+	expected := cfg.Sdump(build())
+	for i := 0; i < 20; i++ {
+		require.Equal(t, expected, cfg.Sdump(build()))
+	}
+
+	// Keys dumped identically are ordered by their values, which here contain the map itself.
+	cyclic := map[*Credentials]interface{}{}
+	cyclic[&Credentials{"user", "secret"}] = cyclic
+	cyclic[&Credentials{"user", "secret"}] = cyclic
+	runTestWithCfg(t, "CanonicalLabels_cyclic", cfg, cyclic)
+}
+
+func TestSdump_ShowFuncLocation(t *testing.T) {
+	cfg := litter.Options{
+		ShowFuncLocation: true,
+	}
+	assert.Regexp(t, `^litter_test\.Function /\* dump_test\.go:\d+ \*/$`, cfg.Sdump(Function))
+	assert.Regexp(t, `^func\(\) /\* dump_test\.go:\d+ \*/$`, cfg.Sdump(func() {}))
+
+	cfg.Compact = true
+	assert.Regexp(t, `^func\(\)/\*dump_test\.go:\d+\*/$`, cfg.Sdump(func() {}))
+}
+
+type failingWriter struct {
+	n int
+}
+
+func (w *failingWriter) Write(b []byte) (int, error) {
+	if w.n == 0 {
+		return 0, io.ErrShortWrite
+	}
+	w.n--
+	return len(b), nil
+}
+
+func TestFdump(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("report: ")
+	require.NoError(t, litter.Options{Compact: true}.Fdump(&b, []int{1, 2}, "x"))
+	assert.Equal(t, `report: []int{1,2}"x"`, b.String())
+
+	err := litter.Options{}.Fdump(&failingWriter{n: 2}, []int{1, 2, 3})
+	assert.Equal(t, io.ErrShortWrite, err)
+
+	// Dumping stops when writing a scalar fails too.
+	assert.Equal(t, io.ErrShortWrite, litter.Options{}.Fdump(&failingWriter{}, 12345))
+	assert.Equal(t, io.ErrShortWrite, litter.Options{}.Fdump(&failingWriter{n: 1}, []interface{}{true}))
+}
+
+func TestOptions_Tee(t *testing.T) {
+	opts := litter.Options{Compact: true}
+	var a, b strings.Builder
+	require.NoError(t, opts.Tee(&a, &b)([]int{1, 2}, "x"))
+	assert.Equal(t, `[]int{1,2}"x"`, a.String())
+	assert.Equal(t, a.String(), b.String())
+
+	opts.MaxOutputBytes = 5
+	a.Reset()
+	b.Reset()
+	require.NoError(t, opts.Tee(&a, &b)([]int{1, 2, 3}))
+	assert.Equal(t, "[]int...", a.String())
+	assert.Equal(t, a.String(), b.String())
+
+	// Writers before the failing one receive one more write than those after it.
+	var before, after strings.Builder
+	err := litter.Options{}.Tee(&before, &failingWriter{n: 2}, &after)([]int{1, 2, 3})
+	assert.Equal(t, io.ErrShortWrite, err)
+	full := litter.Sdump([]int{1, 2, 3})
+	assert.True(t, strings.HasPrefix(full, before.String()))
+	assert.True(t, strings.HasPrefix(before.String(), after.String()))
+	assert.True(t, len(after.String()) < len(before.String()))
+	assert.True(t, len(before.String()) < len(full))
+}
+
+func TestSdumpTree(t *testing.T) {
+	shared := &BasicStruct{1, 2}
+	circular := &RecursiveStruct{}
+	circular.Ptr = circular
+	tree := litter.Options{}.SdumpTree(map[string]interface{}{
+		"basics":   []*BasicStruct{shared, shared, nil},
+		"circular": circular,
+		"name":     "tree",
+	})
+
+	assert.Equal(t, litter.MapNode, tree.Kind)
+	assert.Equal(t, "map[string]interface {}", tree.Type)
+	require.Len(t, tree.Children, 3)
+
+	basics := tree.Child(`"basics"`)
+	require.NotNil(t, basics)
+	assert.Equal(t, litter.SliceNode, basics.Kind)
+	first := basics.Child("0")
+	assert.Equal(t, litter.StructNode, first.Kind)
+	assert.Equal(t, "litter_test.BasicStruct", first.Type)
+	assert.Equal(t, "p0", first.Label)
+	assert.Equal(t, "1", first.Child("Public").Value)
+	assert.Equal(t, &litter.Node{Kind: litter.PointerRefNode, Name: "1", Type: "litter_test.BasicStruct", Value: "p0"}, basics.Child("1"))
+	assert.Equal(t, "nil", basics.Child("2").Value)
+
+	circularNode := tree.Child(`"circular"`)
+	assert.Equal(t, "p1", circularNode.Label)
+	assert.Equal(t, litter.PointerRefNode, circularNode.Child("Ptr").Kind)
+	assert.Equal(t, "p1", circularNode.Child("Ptr").Value)
+
+	assert.Equal(t, &litter.Node{Kind: litter.ScalarNode, Name: `"name"`, Type: "string", Value: `"tree"`}, tree.Child(`"name"`))
+	assert.Nil(t, tree.Child("missing").Child("nested"))
+
+	tree = litter.Options{
+		DumpFunc: func(v reflect.Value, w io.Writer) bool {
+			if v.Kind() != reflect.Bool {
+				return false
+			}
+			io.WriteString(w, "on")
+			return true
+		},
+	}.SdumpTree(map[string]interface{}{"enabled": true, "retries": []int{1, 2}})
+	assert.Equal(t, "boolon", tree.Child(`"enabled"`).Value)
+	assert.Equal(t, litter.SliceNode, tree.Child(`"retries"`).Kind)
+	assert.Equal(t, "2", tree.Child(`"retries"`).Child("1").Value)
+}
+
+func TestNormalizeDump(t *testing.T) {
+	basic := &BasicStruct{1, 2}
+	value := []interface{}{
+		map[string]interface{}{"key with spaces": []int{1, 2}, "basic": basic},
+		basic,
+		Account{Password: "a, }b", Credentials: Credentials{Username: "\"quoted\""}},
+		func(string, int) (bool, error) { return false, nil },
+	}
+
+	expanded := litter.Options{}.Sdump(value)
+	compact := litter.Options{Compact: true}.Sdump(value)
+	require.NotEqual(t, expanded, compact)
+	assert.Equal(t, litter.NormalizeDump(compact), litter.NormalizeDump(expanded))
+	assert.True(t, litter.DumpsEqual(expanded, compact))
+
+	assert.Equal(t, `[]int{1,2/*p0*/}`, litter.NormalizeDump("[]int{\n  1,\n  2, // p0\n}"))
+	assert.False(t, litter.DumpsEqual(`"a b"`, `"ab"`))
+}
+
+func TestNormalizeDump_Labels(t *testing.T) {
+	assert.Equal(t,
+		`[]*p1.T{&p1.T{V:p0}/*p0*/,p0,&T{}/*ptr0*/,ptr0,"p3"/*p1*/,p1,p3(1)}`,
+		litter.NormalizeDump(`[]*p1.T{&p1.T{V:p2}/*p2*/,p2,&T{}/*ptr5*/,ptr5,"p3"/*p3*/,p3,p3(1)}`))
+	assert.Equal(t, "p0:=&T{}p1:=&U{Next:p0}", litter.NormalizeDump("p4 := &T{}\np2 := &U{\n  Next: p4,\n}"))
+
+	value := []interface{}{&BasicStruct{1, 2}, &BasicStruct{3, 4}}
+	value = append(value, value[1], value[0])
+	dumped := litter.Sdump(value)
+	relabelled := strings.NewReplacer("p0", "p7", "p1", "p3").Replace(dumped)
+	assert.True(t, litter.DumpsEqual(dumped, relabelled))
+	assert.False(t, litter.DumpsEqual(dumped, litter.Sdump(append(value[:2:2], value[0], value[1]))))
+}
+
+func TestSupportsFeature(t *testing.T) {
+	assert.Regexp(t, `^\d+\.\d+\.\d+$`, litter.Version)
+	assert.True(t, litter.SupportsFeature("MaxDepth"))
+	assert.True(t, litter.SupportsFeature("Sdump"))
+	assert.True(t, litter.SupportsFeature("FormatYAML"))
+	assert.True(t, litter.SupportsFeature("ReusedPointers"))
+	assert.False(t, litter.SupportsFeature("Teleport"))
+	assert.False(t, litter.SupportsFeature("dumpVal"))
+
+	for _, name := range exportedIdentifiers(t) {
+		assert.True(t, litter.SupportsFeature(name), name)
+	}
+}
+
+// exportedIdentifiers returns the names of the exported functions, types, constants and variables
+// declared by the package.
+func exportedIdentifiers(t *testing.T) []string {
+	files, err := filepath.Glob("*.go")
+	require.NoError(t, err)
+	var names []string
+	for _, name := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(token.NewFileSet(), name, nil, 0)
+		require.NoError(t, err)
+		for _, decl := range file.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				if decl.Recv == nil && decl.Name.IsExported() {
+					names = append(names, decl.Name.Name)
+				}
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					switch spec := spec.(type) {
+					case *ast.TypeSpec:
+						if spec.Name.IsExported() {
+							names = append(names, spec.Name.Name)
+						}
+					case *ast.ValueSpec:
+						for _, ident := range spec.Names {
+							if ident.IsExported() {
+								names = append(names, ident.Name)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	return names
+}
+
+func TestDebug_redirected(t *testing.T) {
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	stdout := os.Stdout
+	os.Stdout = w
+	litter.Debug([]int{1, 2})
+	os.Stdout = stdout
+	require.NoError(t, w.Close())
+
+	out, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, litter.Sdump([]int{1, 2})+"\n", string(out))
+}
+
+func TestSdump_Color(t *testing.T) {
+	dump := litter.Options{Color: true, Compact: true}.Sdump(struct {
+		Name  string
+		Score float64
+		Next  *BasicStruct
+		On    bool
+		Codes [2]uint8
+	}{Name: `say "p0"`, Score: 1.5, On: true})
+	assert.Equal(t, "struct{Name string;Score float64;Next *litter_test.BasicStruct;On bool;Codes [\x1b[36m2\x1b[0m]uint8}"+
+		"{Name:\x1b[32m\"say \\\"p0\\\"\"\x1b[0m,Score:\x1b[36m1.5\x1b[0m,Next:\x1b[35mnil\x1b[0m,On:\x1b[35mtrue\x1b[0m,"+
+		"Codes:[\x1b[36m2\x1b[0m]uint8{\x1b[36m0\x1b[0m,\x1b[36m0\x1b[0m}}", dump)
+
+	shared := &BasicStruct{1, 2}
+	dump = litter.Options{Color: true}.Sdump([]*BasicStruct{shared, shared})
+	assert.Contains(t, dump, "{ \x1b[90m// p0\x1b[0m\n")
+	assert.Contains(t, dump, "Public: \x1b[36m1\x1b[0m,\n")
+}
+
+func TestSdumpWith(t *testing.T) {
+	opts := &litter.Options{Compact: true, Separator: " "}
+	assert.Equal(t, `[]int{1,2} "x"`, litter.SdumpWith(opts, []int{1, 2}, "x"))
+
+	var b strings.Builder
+	require.NoError(t, litter.FdumpWith(opts, &b, &BasicStruct{1, 2}))
+	assert.Equal(t, opts.Sdump(&BasicStruct{1, 2}), b.String())
+}
+
+func TestSdump_OnTruncate(t *testing.T) {
+	var truncations []string
+	opts := &litter.Options{
+		MaxDepth:         3,
+		MaxMapLength:     1,
+		SummarizeAbove:   3,
+		CompactThreshold: 40,
+		OnTruncate: func(reason string, path string) {
+			truncations = append(truncations, reason+" at "+path)
+		},
+	}
+	litter.SdumpWith(opts, map[string]interface{}{
+		"a": []interface{}{[]int{1, 2, 3, 4}, [][]int{{1}}},
+		"b": 2,
+	})
+	assert.Equal(t, []string{
+		"MaxMapLength at ",
+		"SummarizeAbove at [\"a\"][0]",
+		"MaxDepth at [\"a\"][1][0]",
+	}, truncations)
+}
+
+type PayloadDumper struct {
+	Size int
+}
+
+func (d PayloadDumper) LitterDump(w io.Writer) {
+	for i := 0; i < d.Size; i++ {
+		fmt.Fprintf(w, "line %d\n", i)
+	}
+}
+
+func TestSdump_MaxOutputBytes(t *testing.T) {
+	var truncations []string
+	opts := &litter.Options{
+		MaxOutputBytes: 60,
+		OnTruncate: func(reason string, path string) {
+			truncations = append(truncations, reason+" at "+path)
+		},
+	}
+	dump := litter.SdumpWith(opts, []interface{}{PayloadDumper{Size: 100000}})
+	assert.Equal(t, "[]interface {}{\n  litter_test.PayloadDumperline 0\n  line 1\n ...", dump)
+	assert.Equal(t, []string{"MaxOutputBytes at [0]"}, truncations)
+}
+
+func TestSdump_MaxOutputBytesInScalars(t *testing.T) {
+	for _, c := range []struct {
+		limit    int
+		value    interface{}
+		expected string
+	}{
+		{3, 123456, "123..."},
+		{3, true, "tru..."},
+		{4, 3.14159, "3.14..."},
+	} {
+		var truncations []string
+		opts := &litter.Options{
+			MaxOutputBytes: c.limit,
+			OnTruncate: func(reason string, path string) {
+				truncations = append(truncations, reason)
+			},
+		}
+		assert.Equal(t, c.expected, litter.SdumpWith(opts, c.value))
+		assert.Equal(t, []string{"MaxOutputBytes"}, truncations)
+	}
+}
+
+func TestSdump_MaxOutputBytesAcrossValues(t *testing.T) {
+	var truncations []string
+	opts := &litter.Options{
+		MaxOutputBytes: 20,
+		Compact:        true,
+		Separator:      " ",
+		OnTruncate: func(reason string, path string) {
+			truncations = append(truncations, reason+" at "+path)
+		},
+	}
+	assert.Equal(t, "[]int{1,2,3}", litter.SdumpWith(opts, []int{1, 2, 3}))
+	assert.Equal(t, "[]int{1,2,3} []int{4...", litter.SdumpWith(opts, []int{1, 2, 3}, []int{4, 5, 6}))
+	assert.Equal(t, "[]int{1,2,3,4,5,6,7,...", litter.SdumpWith(opts, []int{1, 2, 3, 4, 5, 6, 7, 8}, []int{9}, []int{10}))
+	assert.Equal(t, []string{"MaxOutputBytes at ", "MaxOutputBytes at [7]"}, truncations)
+
+	var b strings.Builder
+	require.NoError(t, opts.Fdump(&b, "first value", "second value"))
+	assert.Equal(t, `"first value" "secon...`, b.String())
+}
+
+func TestSdumpWithRegions(t *testing.T) {
+	type Person struct {
+		Name    string
+		Tags    []string
+		Manager *Person
+	}
+	bob := &Person{Name: "bob", Tags: []string{"a", "b"}}
+	alice := Person{Name: "alice", Manager: bob}
+	dump, regions := litter.Options{Compact: true, HomePackage: "litter_test"}.SdumpWithRegions(alice, 42)
+	assert.Equal(t, `Person{Name:"alice",Tags:[]string(nil),Manager:&Person{Name:"bob",Tags:[]string{"a","b"},Manager:nil}}42`, dump)
+
+	texts := make([]string, len(regions))
+	for i, r := range regions {
+		texts[i] = r.Path + " = " + dump[r.Start:r.End]
+	}
+	assert.Equal(t, []string{
+		` = ` + dump[:len(dump)-2],
+		`Name = "alice"`,
+		`Tags = []string(nil)`,
+		`Manager = &Person{Name:"bob",Tags:[]string{"a","b"},Manager:nil}`,
+		`Manager = Person{Name:"bob",Tags:[]string{"a","b"},Manager:nil}`,
+		`Manager.Name = "bob"`,
+		`Manager.Tags = []string{"a","b"}`,
+		`Manager.Tags[0] = "a"`,
+		`Manager.Tags[1] = "b"`,
+		`Manager.Manager = nil`,
+		` = 42`,
+	}, texts)
+	assert.Equal(t, reflect.TypeOf(bob), regions[3].Value.Type())
+}
+
+func TestSdump_MaxPointerDepth(t *testing.T) {
+	x := 5
+	p1 := &x
+	p2 := &p1
+	p3 := &p2
+	assert.Equal(t, "&&&5", litter.Options{Compact: true}.Sdump(p3))
+	assert.Regexp(t, `^&\(\*\*int\)\(0x[0-9a-f]+\)$`, litter.Options{Compact: true, MaxPointerDepth: 1}.Sdump(p3))
+	assert.Regexp(t, `^&&\(\*int\)\(0x[0-9a-f]+\)$`, litter.Options{Compact: true, MaxPointerDepth: 2}.Sdump(p3))
+	assert.Equal(t, "&&&5", litter.Options{Compact: true, MaxPointerDepth: 3}.Sdump(p3))
+
+	opts := litter.Options{Compact: true, MaxPointerDepth: 1}
+	assert.Equal(t, "[]interface{}{&5,/*p0*/&p0}", opts.Sdump([]interface{}{p1, p2}))
+	assert.Regexp(t, `^struct\{Ptr \*\*int;Nil \*\*int\}\{Ptr:&\(\*int\)\(0x[0-9a-f]+\),Nil:nil\}$`, opts.Sdump(struct {
+		Ptr **int
+		Nil **int
+	}{Ptr: p2}))
+
+	var truncations []string
+	opts.OnTruncate = func(reason string, path string) {
+		truncations = append(truncations, reason+" at "+path)
+	}
+	opts.Sdump(struct{ Ptr **int }{Ptr: p2})
+	assert.Equal(t, []string{"MaxPointerDepth at Ptr"}, truncations)
+}
+
+func TestSdump_ShowSizesOfLongLists(t *testing.T) {
+	type Link struct {
+		Value int
+		Next  *Link
+	}
+	var list *Link
+	for i := 0; i < 1000; i++ {
+		list = &Link{Value: i, Next: list}
+	}
+	// Each link is measured once, rather than again for each link before it.
+	dump := litter.Options{ShowSizes: true, Compact: true}.Sdump(list)
+	total := 1000 * float64(unsafe.Sizeof(Link{}))
+	assert.True(t, strings.HasSuffix(dump, fmt.Sprintf("}/*~%.1f KB*/", total/1024)), dump[len(dump)-40:])
+}
+
+type StackError struct {
+	Message string
+	stack   []uintptr
+}
+
+func newStackError(message string) *StackError {
+	pcs := make([]uintptr, 2)
+	n := runtime.Callers(1, pcs)
+	return &StackError{Message: message, stack: pcs[:n]}
+}
+
+func (e *StackError) Error() string         { return e.Message }
+func (e *StackError) StackTrace() []uintptr { return e.stack }
+
+func TestSdump_ErrorStackTraces(t *testing.T) {
+	value := struct {
+		Err     error
+		Missing *StackError
+	}{Err: newStackError("failed")}
+	dump := litter.Options{ErrorStackTraces: true}.Sdump(value)
+	assert.Equal(t, 1, strings.Count(dump, "/* stack:"))
+	assert.Contains(t, dump, "litter_test.newStackError (dump_test.go:")
+	assert.Contains(t, dump, "litter_test.TestSdump_ErrorStackTraces (dump_test.go:")
+	assert.Contains(t, dump, "Missing: nil,")
+	assert.NotContains(t, litter.Sdump(value), "stack")
+
+	compact := litter.Options{ErrorStackTraces: true, Compact: true}.Sdump(value)
+	assert.Contains(t, compact, "/*stack: litter_test.newStackError (dump_test.go:")
+}
+
+func TestNewStateful(t *testing.T) {
+	other := &BasicStruct{3, 4}
+	shared := &BasicStruct{1, 2}
+	fresh := &BasicStruct{5, 6}
+	d := litter.NewStateful(litter.Options{Compact: true, HidePrivateFields: true})
+	assert.Equal(t,
+		`[]interface{}{&litter_test.BasicStruct{/*p0*/Public:3},p0,&litter_test.BasicStruct{/*p1*/Public:1},p1}`,
+		d.Sdump([]interface{}{other, other, shared, shared}))
+	assert.Equal(t,
+		`[]interface{}{&litter_test.BasicStruct{/*p2*/Public:5},p2,&litter_test.BasicStruct{/*p1*/Public:1},p1}`,
+		d.Sdump([]interface{}{fresh, fresh, shared, shared}))
 }
 
 func TestSdump_multipleArgs(t *testing.T) {
@@ -243,6 +1552,618 @@ func TestSdump_maps(t *testing.T) {
 	})
 }
 
+func TestSdump_orderedMaps(t *testing.T) {
+	runTests(t, "orderedMaps", []interface{}{
+		ReverseOrderedMap{
+			1: "one",
+			2: "two",
+			3: "three",
+		},
+		ReverseOrderedMap{
+			1: "one",
+			5: "five",
+			4: "four",
+			3: "three",
+		},
+		MismatchedOrderedMap{"2": 2, "Z": 90, "a": 1, "b": 2},
+	})
+}
+
+func TestSdump_time(t *testing.T) {
+	type Event struct {
+		Name string
+		At   time.Time
+		Seen *time.Time
+	}
+
+	at := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+	seen := time.Date(2024, 1, 2, 4, 0, 0, 0, time.FixedZone("CET", 3600))
+	data := []interface{}{
+		at,
+		Event{Name: "start", At: at, Seen: &seen},
+		time.Time{},
+	}
+
+	runTests(t, "time", data)
+	runTestWithCfg(t, "time_Compact", &litter.Options{
+		Compact: true,
+	}, data)
+	runTestWithCfg(t, "time_TimeLocation", &litter.Options{
+		TimeLocation: time.FixedZone("EST", -5*3600),
+	}, data)
+	runTestWithCfg(t, "time_TimeFormat_unix", &litter.Options{
+		TimeFormat: "unix",
+	}, data)
+	runTestWithCfg(t, "time_TimeFormat_unixmilli", &litter.Options{
+		TimeFormat: "unixmilli",
+		Compact:    true,
+	}, data)
+	runTestWithCfg(t, "time_TimeFormat_layout", &litter.Options{
+		TimeFormat:   time.Kitchen,
+		TimeLocation: time.UTC,
+	}, data)
+	runTestWithCfg(t, "time_StrictGo", &litter.Options{
+		StrictGo:   true,
+		TimeFormat: "unix",
+	}, data)
+	runTestWithCfg(t, "time_StrictGo_Compact", &litter.Options{
+		StrictGo: true,
+		Compact:  true,
+	}, data)
+}
+
+func TestSdump_compactThreshold(t *testing.T) {
+	// The compact form of []int{1, 2, 3} is exactly 12 bytes long.
+	assert.Equal(t, "[]int{1,2,3}", litter.Options{CompactThreshold: 12}.Sdump([]int{1, 2, 3}))
+	assert.Equal(t, "[]int{\n  1,\n  2,\n  3,\n}", litter.Options{CompactThreshold: 11}.Sdump([]int{1, 2, 3}))
+	// The threshold is crossed in the middle of 12345.
+	assert.Equal(t, "[]int{\n  1,\n  2,\n  12345,\n}", litter.Options{CompactThreshold: 12}.Sdump([]int{1, 2, 12345}))
+
+	// shared is labelled once, although it is first dumped while trying to collapse its parent.
+	shared := &BasicStruct{Public: 1, private: 2}
+	runTestWithCfg(t, "compactThreshold", &litter.Options{
+		CompactThreshold: 40,
+	}, [][]interface{}{
+		{[]int{1, 2}, map[string]int{"a": 1}},
+		{shared, "a string too long to collapse", shared},
+		{shared},
+	})
+}
+
+func TestSdump_formatYAML(t *testing.T) {
+	type Person struct {
+		Name    string
+		Age     int
+		Tags    []string
+		Parent  *Person
+		Friends []*Person
+		Extra   map[string]interface{}
+	}
+
+	jane := &Person{Name: "Jane", Age: 50}
+	bob := &Person{
+		Name:    "Bob",
+		Age:     20,
+		Tags:    []string{"a", "b"},
+		Parent:  jane,
+		Friends: []*Person{jane, {Name: "Alice"}},
+		Extra: map[string]interface{}{
+			"empty":  []int{},
+			"matrix": [][]int{{1, 2}, {3}},
+			"nested": map[int]bool{1: true},
+			"nil":    nil,
+		},
+	}
+	circular := &RecursiveStruct{}
+	circular.Ptr = circular
+
+	runTestWithCfg(t, "format_YAML", &litter.Options{
+		Format:    litter.FormatYAML,
+		Separator: "\n---\n",
+	}, bob, circular, 42, "string", nil)
+
+	runTestWithCfg(t, "format_YAML_DumpFunc", &litter.Options{
+		Format: litter.FormatYAML,
+		DumpFunc: func(v reflect.Value, w io.Writer) bool {
+			if v.Kind() != reflect.Bool {
+				return false
+			}
+			io.WriteString(w, map[bool]string{true: `"on"`, false: `"off"`}[v.Bool()])
+			return true
+		},
+	}, map[string]interface{}{"enabled": true, "retries": []int{1, 2}})
+}
+
+func TestSdump_formatYAML_TagInterfaceFields(t *testing.T) {
+	type Drawing struct {
+		Background interface{}
+		Shape      interface{}
+		Outline    interface{}
+		Label      interface{}
+		Missing    interface{}
+	}
+
+	shape := &BasicStruct{1, 2}
+	runTestWithCfg(t, "format_YAML_TagInterfaceFields", &litter.Options{
+		Format:             litter.FormatYAML,
+		TagInterfaceFields: true,
+		StripPackageNames:  true,
+	}, []Drawing{
+		{Background: BlankStruct{}, Shape: shape, Outline: shape, Label: "label"},
+	})
+}
+
+func TestSdump_formatRepr(t *testing.T) {
+	type Person struct {
+		Name    string
+		Age     int
+		Score   float64
+		Active  bool
+		Parent  *Person
+		Friends []*Person
+		Extra   map[string]interface{}
+	}
+
+	jane := &Person{Name: "Jane", Age: 50, Score: 1.5}
+	bob := &Person{
+		Name:    "Bob's",
+		Age:     20,
+		Active:  true,
+		Parent:  jane,
+		Friends: []*Person{jane},
+		Extra: map[string]interface{}{
+			"inf":    math.Inf(1),
+			"matrix": [][]int{{1, 2}, {3}},
+			"nil":    nil,
+			"quote":  `say "hi"`,
+		},
+	}
+	circular := &RecursiveStruct{}
+	circular.Ptr = circular
+	cyclicMap := map[string]interface{}{}
+	cyclicMap["self"] = cyclicMap
+
+	runTestWithCfg(t, "format_Repr", &litter.Options{
+		Format:    litter.FormatRepr,
+		Separator: "\n",
+	}, bob, circular, cyclicMap, []interface{}{complex(1, -2), uint8(7)}, nil)
+
+	runTestWithCfg(t, "format_Repr_DumpFunc", &litter.Options{
+		Format: litter.FormatRepr,
+		DumpFunc: func(v reflect.Value, w io.Writer) bool {
+			if v.Kind() != reflect.Bool {
+				return false
+			}
+			io.WriteString(w, map[bool]string{true: `"on"`, false: `"off"`}[v.Bool()])
+			return true
+		},
+	}, map[string]interface{}{"enabled": true, "retries": []int{1, 2}})
+}
+
+func TestSdump_formatFlatPaths(t *testing.T) {
+	type Address struct {
+		City string
+		Zip  int
+	}
+	type User struct {
+		Name      string
+		Addresses []Address
+		Settings  map[string]interface{}
+		Tags      []string
+		Parent    *User
+	}
+
+	user := &User{
+		Name:      "bob",
+		Addresses: []Address{{City: "NYC", Zip: 10001}, {City: "Oslo"}},
+		Settings:  map[string]interface{}{"theme": "dark", "limits": []int{1, 2}, "none": nil},
+	}
+	user.Parent = user
+	runTestWithCfg(t, "format_FlatPaths", &litter.Options{
+		Format:    litter.FormatFlatPaths,
+		Separator: "\n---\n",
+	}, struct{ User *User }{user}, []int{1, 2}, 42, nil)
+
+	cyclicMap := map[string]interface{}{"name": "map"}
+	cyclicMap["self"] = cyclicMap
+	cyclicSlice := []interface{}{"slice", nil}
+	cyclicSlice[1] = cyclicSlice
+	runTestWithCfg(t, "format_FlatPaths_cycles", &litter.Options{
+		Format:    litter.FormatFlatPaths,
+		Separator: "\n---\n",
+	}, cyclicMap, []interface{}{cyclicSlice})
+
+	runTestWithCfg(t, "format_FlatPaths_DumpFunc", &litter.Options{
+		Format: litter.FormatFlatPaths,
+		DumpFunc: func(v reflect.Value, w io.Writer) bool {
+			if v.Kind() != reflect.Bool {
+				return false
+			}
+			io.WriteString(w, map[bool]string{true: `"on"`, false: `"off"`}[v.Bool()])
+			return true
+		},
+	}, map[string]interface{}{"enabled": true, "retries": []int{1, 2}})
+
+	var list *RecursiveStruct
+	for i := 0; i < 100; i++ {
+		list = &RecursiveStruct{Ptr: list}
+	}
+	dump := litter.Options{Format: litter.FormatFlatPaths, MaxRecursionDepth: 10}.Sdump(list)
+	assert.Equal(t, ".Ptr.Ptr.Ptr.Ptr.Ptr.Ptr.Ptr.Ptr.Ptr.Ptr = <max recursion exceeded>", dump)
+}
+
+func TestSdump_formatLogfmt(t *testing.T) {
+	type Address struct {
+		City string
+	}
+	type User struct {
+		ID      int
+		Name    string
+		Admin   bool
+		Address *Address
+		Tags    []string
+		Parent  *User
+		Joined  time.Time
+		Labels  map[string]string
+	}
+
+	user := &User{
+		ID:      1,
+		Name:    "bob",
+		Address: &Address{City: "Oslo"},
+		Tags:    []string{"a", "b"},
+		Joined:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Labels:  map[string]string{"Env": "prod"},
+	}
+	user.Parent = user
+	cyclicMap := map[string]interface{}{"Name": "loop"}
+	cyclicMap["Self"] = cyclicMap
+	runTestWithCfg(t, "format_Logfmt", &litter.Options{
+		Format:    litter.FormatLogfmt,
+		Separator: "\n",
+	}, struct{ User *User }{user}, cyclicMap, map[string]int{"not a key": 1}, 42, nil)
+
+	runTestWithCfg(t, "format_Logfmt_DumpFunc", &litter.Options{
+		Format: litter.FormatLogfmt,
+		DumpFunc: func(v reflect.Value, w io.Writer) bool {
+			if v.Kind() != reflect.Bool {
+				return false
+			}
+			io.WriteString(w, map[bool]string{true: `"on"`, false: `"off"`}[v.Bool()])
+			return true
+		},
+	}, struct {
+		Enabled bool
+		Retries int
+	}{true, 3})
+}
+
+// otherFormats are the formats other than Go, by name.
+var otherFormats = map[string]litter.Format{
+	"YAML":      litter.FormatYAML,
+	"Repr":      litter.FormatRepr,
+	"Logfmt":    litter.FormatLogfmt,
+	"FlatPaths": litter.FormatFlatPaths,
+}
+
+func TestSdump_formatsRedactTypes(t *testing.T) {
+	type Secret string
+	type Login struct {
+		User  string
+		Token Secret
+		Pass  *Secret
+	}
+	type Vault struct {
+		Login Login
+		Keys  map[Secret]int
+	}
+	pass := Secret("hunter2")
+	vault := Vault{
+		Login: Login{User: "bob", Token: "tok123", Pass: &pass},
+		Keys:  map[Secret]int{"k3y": 1},
+	}
+	redactTypes := []reflect.Type{reflect.TypeOf(Secret(""))}
+
+	for name, format := range otherFormats {
+		opts := &litter.Options{Format: format, RedactTypes: redactTypes}
+		for _, secret := range []string{"tok123", "hunter2", "k3y"} {
+			assert.NotContains(t, opts.Sdump(vault), secret, name)
+		}
+		runTestWithCfg(t, "format_"+name+"_RedactTypes", opts, vault)
+	}
+
+	tree := litter.Options{RedactTypes: redactTypes}.SdumpTree(vault)
+	assert.Equal(t, "<redacted>", tree.Child("Login").Child("Token").Value)
+	assert.Equal(t, "<redacted>", tree.Child("Login").Child("Pass").Value)
+	assert.Equal(t, "<redacted>", tree.Child("Keys").Children[0].Name)
+}
+
+func TestSdump_formatsMaskStrings(t *testing.T) {
+	type Contact struct {
+		Name   string
+		Email  string            `litter:"mask"`
+		Phones map[string]string `litter:"mask"`
+	}
+	contact := Contact{
+		Name:   "bob",
+		Email:  "bob@example.com",
+		Phones: map[string]string{"home": "555-0100"},
+	}
+
+	for name, format := range otherFormats {
+		opts := &litter.Options{Format: format}
+		dump := opts.Sdump(contact)
+		assert.Contains(t, dump, "bob", name)
+		for _, masked := range []string{"example", "home", "555"} {
+			assert.NotContains(t, dump, masked, name)
+		}
+		runTestWithCfg(t, "format_"+name+"_maskTag", opts, contact)
+
+		opts.MaskStrings = true
+		assert.NotContains(t, opts.Sdump(contact), "bob", name)
+	}
+
+	tree := litter.Options{}.SdumpTree(contact)
+	assert.Equal(t, `"bob"`, tree.Child("Name").Value)
+	assert.Equal(t, "string(len=15)", tree.Child("Email").Value)
+	assert.Equal(t, "string(len=4)", tree.Child("Phones").Children[0].Name)
+}
+
+func TestSdump_formatsLimits(t *testing.T) {
+	type Team struct {
+		Name    string
+		Members []string
+		Scores  map[string]int
+		Lead    *Team
+	}
+	team := Team{
+		Name:    "core",
+		Members: []string{"ann", "bob", "cid", "dee"},
+		Scores:  map[string]int{"ann": 1, "bob": 2},
+		Lead:    &Team{Name: "lead", Members: []string{"eve"}},
+	}
+
+	for name, format := range otherFormats {
+		runTestWithCfg(t, "format_"+name+"_limits", &litter.Options{
+			Format:         format,
+			MaxDepth:       2,
+			SummarizeAbove: 3,
+			MaxMapLength:   1,
+		}, team)
+	}
+
+	tree := litter.Options{MaxDepth: 2, SummarizeAbove: 3, MaxMapLength: 1}.SdumpTree(team)
+	assert.Equal(t, "[]string(len=4)", tree.Child("Members").Value)
+	assert.Len(t, tree.Child("Scores").Children, 1)
+	assert.Equal(t, "[]string{...}", tree.Child("Lead").Child("Members").Value)
+}
+
+func TestSdump_internStrings(t *testing.T) {
+	type LogEntry struct {
+		Level   string
+		Message string
+	}
+
+	message := "connection refused by upstream server"
+	data := []LogEntry{
+		{Level: "error", Message: message},
+		{Level: "error", Message: message},
+		{Level: "info", Message: "retrying"},
+		{Level: "error", Message: message},
+	}
+
+	runTestWithCfg(t, "internStrings", &litter.Options{
+		InternStrings:   true,
+		MinInternLength: 10,
+	}, data)
+	runTestWithCfg(t, "internStrings_Compact", &litter.Options{
+		Compact:         true,
+		InternStrings:   true,
+		MinInternLength: 10,
+	}, data)
+}
+
+func TestSdump_interfaceMapKeys(t *testing.T) {
+	runTests(t, "interfaceMapKeys", map[interface{}]int{
+		10:    1,
+		9:     2,
+		-1:    3,
+		"b":   4,
+		"a":   5,
+		"10":  6,
+		2.5:   7,
+		true:  8,
+		false: 9,
+		nil:   10,
+	})
+	// NaN keys come after the other numbers. Their values cannot be looked up, so they are cut.
+	runTestWithCfg(t, "interfaceMapKeys_NaN", &litter.Options{
+		MaxMapLength: 3,
+	}, map[interface{}]int{math.NaN(): 1, 2.5: 2, math.NaN(): 3, math.Inf(-1): 4, 1.0: 5})
+	// Keys of concrete types are ordered by their dumps, so 10 comes before 9.
+	runTests(t, "intMapKeys", map[int]string{10: "ten", 9: "nine", -1: "minus one", 100: "hundred"})
+}
+
+func TestSdump_sqlNullTypes(t *testing.T) {
+	type Row struct {
+		Name    sql.NullString
+		Age     sql.NullInt64
+		Score   sql.NullFloat64
+		Deleted sql.NullTime
+	}
+
+	data := []Row{
+		{Name: sql.NullString{String: "Bob", Valid: true}, Age: sql.NullInt64{Int64: 42, Valid: true}},
+		{Name: sql.NullString{String: "ignored"}},
+	}
+	runTests(t, "sqlNullTypes", data)
+	runTestWithCfg(t, "sqlNullTypes_DisableDefaultDumpers", &litter.Options{
+		DisableDefaultDumpers: true,
+	}, data[0].Name)
+}
+
+func TestSdump_jsonNumbers(t *testing.T) {
+	numbers := []interface{}{json.Number("42"), json.Number("-1.5e3"), json.Number("not a number"), json.Number("")}
+	runTests(t, "jsonNumbers", numbers)
+	runTestWithCfg(t, "jsonNumbers_ShowScalarTypes", &litter.Options{
+		ShowScalarTypes: true,
+	}, numbers)
+}
+
+func TestSdump_deduplicateSubtrees(t *testing.T) {
+	type Node struct {
+		Name     string
+		Children []*Node
+	}
+
+	leaf := func() *Node {
+		return &Node{Name: "leaf", Children: []*Node{{Name: "a"}, {Name: "b"}}}
+	}
+	shared := &Node{Name: "shared"}
+	tree := &Node{
+		Name: "root",
+		Children: []*Node{
+			leaf(),
+			leaf(),
+			{Name: "other", Children: []*Node{leaf(), shared}},
+			{Name: "other", Children: []*Node{leaf(), shared}},
+		},
+	}
+	runTestWithCfg(t, "deduplicateSubtrees", &litter.Options{
+		DeduplicateSubtrees: true,
+		MinSubtreeSize:      50,
+		StripPackageNames:   true,
+	}, tree)
+	runTestWithCfg(t, "deduplicateSubtrees_CompactThreshold", &litter.Options{
+		DeduplicateSubtrees: true,
+		MinSubtreeSize:      50,
+		StripPackageNames:   true,
+		CompactThreshold:    150,
+	}, tree)
+}
+
+func TestSdump_containers(t *testing.T) {
+	l := list.New()
+	l.PushBack(1)
+	l.PushBack("two")
+	l.PushBack(&BasicStruct{3, 4})
+	r := ring.New(3)
+	for i := 0; i < 3; i++ {
+		r.Value = i
+		r = r.Next()
+	}
+	runTests(t, "containers", map[string]interface{}{
+		"list":        l,
+		"ring":        r.Next(),
+		"empty list":  list.New(),
+		"single ring": &ring.Ring{Value: "one"},
+		"list value":  *l,
+	})
+
+	// Other formats dump the internals of the containers, reusing the pointers between them.
+	short := list.New()
+	short.PushBack(1)
+	short.PushBack("two")
+	pair := ring.New(2)
+	pair.Value = 1
+	pair.Next().Value = 2
+	runTestWithCfg(t, "containers_YAML", &litter.Options{
+		Format:    litter.FormatYAML,
+		Separator: "\n---\n",
+	}, short, pair)
+	runTestWithCfg(t, "containers_Repr", &litter.Options{
+		Format:    litter.FormatRepr,
+		Separator: "\n",
+	}, short, pair)
+}
+
+func TestSdump_maxDepth(t *testing.T) {
+	tree := buildTree(3, 2)
+	runTestWithCfg(t, "maxDepth", &litter.Options{
+		MaxDepth: 3,
+	}, tree, map[string][]int{"empty": {}, "full": {1}})
+}
+
+func TestSdump_maxRecursionDepth(t *testing.T) {
+	chain := func(length int) *RecursiveStruct {
+		head := &RecursiveStruct{}
+		for i := 1; i < length; i++ {
+			head = &RecursiveStruct{Ptr: head}
+		}
+		return head
+	}
+
+	runTestWithCfg(t, "maxRecursionDepth", &litter.Options{
+		MaxRecursionDepth: 5,
+	}, chain(10))
+
+	dump := litter.Options{Compact: true}.Sdump(chain(1000000))
+	assert.Contains(t, dump, "{Ptr:<max recursion exceeded>}")
+}
+
+func TestSdump_dumpBufferContents(t *testing.T) {
+	type Request struct {
+		Method string
+		Body   io.Reader
+		Buffer *bytes.Buffer
+	}
+
+	buf := bytes.NewBufferString("hello, world")
+	_, _ = buf.ReadString(' ')
+	data := []Request{
+		{Method: "GET", Body: strings.NewReader("body"), Buffer: buf},
+		{Method: "POST", Body: bytes.NewBufferString("payload")},
+		{Method: "PUT", Body: (*bytes.Buffer)(nil)},
+	}
+
+	runTestWithCfg(t, "dumpBufferContents", &litter.Options{
+		HidePrivateFields:  true,
+		DumpBufferContents: true,
+	}, data)
+	runTestWithCfg(t, "dumpBufferContents_DisableDefaultDumpers", &litter.Options{
+		HidePrivateFields:     true,
+		DumpBufferContents:    true,
+		DisableDefaultDumpers: true,
+	}, data)
+	assert.Equal(t, "world", buf.String())
+}
+
+func TestReusedPointers(t *testing.T) {
+	shared := &BasicStruct{Public: 1}
+	circular := &RecursiveStruct{}
+	circular.Ptr = circular
+	value := []interface{}{shared, shared, shared, circular, &BasicStruct{Public: 2}}
+
+	stats := litter.ReusedPointers(value)
+	require.Len(t, stats, 2)
+	assert.Equal(t, reflect.TypeOf(BasicStruct{}), stats[0].Type)
+	assert.Equal(t, 3, stats[0].Count)
+	assert.Equal(t, reflect.ValueOf(shared).Pointer(), stats[0].Addr)
+	assert.Equal(t, reflect.TypeOf(RecursiveStruct{}), stats[1].Type)
+	assert.Equal(t, 2, stats[1].Count)
+
+	assert.Empty(t, litter.ReusedPointers([]*BasicStruct{{}, nil, nil}))
+}
+
+func TestReusedPointers_nested(t *testing.T) {
+	type Node struct {
+		Name     string
+		Children []*Node
+	}
+
+	// The references to leaf from within parent are counted once, however often parent is.
+	leaf := &Node{Name: "leaf"}
+	parent := &Node{Name: "parent", Children: []*Node{leaf, leaf}}
+	value := []*Node{parent, parent, parent, {Children: []*Node{parent}}}
+
+	stats := litter.ReusedPointers(value)
+	require.Len(t, stats, 2)
+	assert.Equal(t, reflect.ValueOf(parent).Pointer(), stats[0].Addr)
+	assert.Equal(t, 4, stats[0].Count)
+	assert.Equal(t, reflect.ValueOf(leaf).Pointer(), stats[1].Addr)
+	assert.Equal(t, 2, stats[1].Count)
+}
+
 func TestSdump_RecursiveMaps(t *testing.T) {
 	mp := make(map[*RecursiveStruct]*RecursiveStruct)
 	k1 := &RecursiveStruct{}
@@ -258,6 +2179,65 @@ func TestSdump_RecursiveMaps(t *testing.T) {
 	runTests(t, "recursive_maps", mp)
 }
 
+func BenchmarkSdump_deepTree(b *testing.B) {
+	tree := buildTree(8, 3)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		litter.Sdump(tree)
+	}
+}
+
+func BenchmarkSdump_deepTreeMaxDepth(b *testing.B) {
+	tree := buildTree(8, 3)
+	cfg := litter.Options{MaxDepth: 4}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cfg.Sdump(tree)
+	}
+}
+
+func BenchmarkSdump_homePackage(b *testing.B) {
+	value := map[string]BasicStruct{"a": {1, 2}, "b": {3, 4}, "c": {5, 6}}
+	cfg := &litter.Options{HomePackage: "litter_test"}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		litter.SdumpWith(cfg, value)
+	}
+}
+
+// FlatRecord is a large struct of scalars, like a row of a wide table.
+type FlatRecord struct {
+	ID, Count, Total, Min, Max, Sum  int64
+	A, B, C, D, E, F, G, H           string
+	X, Y, Z, W                       float64
+	Active, Deleted, Visible, Locked bool
+	U1, U2, U3, U4                   uint32
+}
+
+func BenchmarkSdump_flatRecords(b *testing.B) {
+	records := make([]FlatRecord, 100)
+	for i := range records {
+		records[i] = FlatRecord{ID: int64(i), A: "a", H: "h", X: 1.5, Active: true, U4: 4}
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		litter.Sdump(records)
+	}
+}
+
+func BenchmarkFdump_strings(b *testing.B) {
+	value := map[string][]string{}
+	for i := 0; i < 50; i++ {
+		value[fmt.Sprint("key", i)] = []string{"a", "bb", "ccc"}
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = litter.Fdump(io.Discard, value)
+	}
+}
+
 var standardCfg = litter.Options{}
 
 func runTestWithCfg(t *testing.T, name string, cfg *litter.Options, cases ...interface{}) {
@@ -0,0 +1,75 @@
+package litter
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strconv"
+)
+
+// defaultDumpers render well-known types more readably than their internals would. They are
+// consulted in order after DumpFunc and Dumper implementations, unless DisableDefaultDumpers is
+// set. Each receives the value as passed to dumpVal, which may be an interface, and returns false
+// if it does not handle the value.
+var defaultDumpers []func(s *dumpState, value reflect.Value) bool
+
+func init() {
+	// Assigned here rather than in the declaration, since dumpers may recurse into dumpVal, which
+	// refers back to the list.
+	defaultDumpers = []func(s *dumpState, value reflect.Value) bool{
+		dumpTime,
+		dumpBuffer,
+		dumpProto,
+		dumpSQLNull,
+		dumpJSONNumber,
+		dumpAtomic,
+		dumpSync,
+		dumpContainer,
+	}
+}
+
+var (
+	bufferPtrType = reflect.TypeOf((*bytes.Buffer)(nil))
+	readerType    = reflect.TypeOf((*io.Reader)(nil)).Elem()
+)
+
+func (s *dumpState) dumpWithDefaultDumpers(value reflect.Value) bool {
+	if s.config.DisableDefaultDumpers {
+		return false
+	}
+	for _, dumper := range defaultDumpers {
+		if dumper(s, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// dumpBuffer dumps a *bytes.Buffer as its unread content, and other values held by io.Reader
+// interfaces by their type only, since reading them would consume them. Enabled by
+// DumpBufferContents.
+func dumpBuffer(s *dumpState, value reflect.Value) bool {
+	if !s.config.DumpBufferContents {
+		return false
+	}
+	v := deInterface(value)
+	if v.Type() == bufferPtrType && v.IsNil() {
+		printNil(s)
+		return true
+	}
+	if v.Type() == bufferPtrType && v.CanInterface() {
+		s.writeString(s.qualifiedName("bytes.NewBufferString"))
+		s.writeString("(")
+		s.writeString(strconv.Quote(v.Interface().(*bytes.Buffer).String()))
+		s.writeString(")")
+		return true
+	}
+	if value.Kind() == reflect.Interface && value.Type().Implements(readerType) {
+		s.writeString("<")
+		s.writeString(s.typeName(value.Type()))
+		s.writeString(">")
+		s.inlineComment(s.typeName(v.Type()))
+		return true
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package litter
+
+import (
+	"reflect"
+	"strings"
+)
+
+// dumpFlatPaths dumps the value as one assignment per line for each scalar it contains, like
+// .User.Addresses[0].City = "NYC". Empty collections, nil values and values dumped by custom
+// dumpers are assigned as a whole, and values containing themselves, through pointers, maps or
+// slices, are assigned a reference to the path they were first found at.
+func (s *dumpState) dumpFlatPaths(value reflect.Value) {
+	first := true
+	s.flatPathsNode(value, &first)
+}
+
+// flatPathsNode writes the assignments of a value, descending into structs, slices, arrays and
+// maps.
+func (s *dumpState) flatPathsNode(value reflect.Value, first *bool) {
+	s.recursion++
+	defer func() { s.recursion-- }()
+	v := deInterface(value)
+	for {
+		if text, ok := s.leafText(v); ok {
+			s.flatPathsLine(first, text)
+			return
+		}
+		if !isPointerValue(v) || v.IsNil() {
+			break
+		}
+		if path, ok := s.parentPath(v); ok {
+			s.flatPathsLine(first, "<circular reference to "+path+">")
+			return
+		}
+		defer s.enterParent(v, s.flatPath())()
+		if v.Kind() != reflect.Ptr {
+			break
+		}
+		v = deInterface(v.Elem())
+	}
+
+	if !v.IsValid() {
+		s.flatPathsLine(first, "nil")
+		return
+	}
+	if v.Kind() == reflect.Interface || v.Type() == timeType {
+		s.flatPathsLine(first, s.compactString(v))
+		return
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		fields := s.visibleFields(v)
+		if len(fields) == 0 {
+			break
+		}
+		for _, i := range fields {
+			s.inField(v, i, func(field reflect.Value) {
+				s.flatPathsNode(field, first)
+			})
+		}
+		return
+	case reflect.Slice, reflect.Array:
+		if v.Len() == 0 || v.Kind() == reflect.Slice && v.IsNil() {
+			break
+		}
+		for i := 0; i < v.Len(); i++ {
+			s.pushIndex(i)
+			s.flatPathsNode(v.Index(i), first)
+			s.popPath()
+		}
+		return
+	case reflect.Map:
+		keys, _ := s.dumpedMapKeys(v)
+		if len(keys) == 0 {
+			break
+		}
+		for _, key := range keys {
+			s.pushKey(key)
+			s.flatPathsNode(v.MapIndex(key), first)
+			s.popPath()
+		}
+		return
+	}
+	s.flatPathsLine(first, s.compactString(v))
+}
+
+// flatPathsLine writes the assignment of the text to the current path.
+func (s *dumpState) flatPathsLine(first *bool, text string) {
+	if !*first {
+		s.writeString("\n")
+	}
+	*first = false
+	s.writeString(s.flatPath() + " = " + text)
+}
+
+// flatPath returns the current path, starting with a dot for the dumped value itself.
+func (s *dumpState) flatPath() string {
+	path := s.pathString()
+	if !strings.HasPrefix(path, "[") {
+		path = "." + path
+	}
+	return path
+}
@@ -0,0 +1,112 @@
+package litter
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+)
+
+// redactedText replaces the values of the types listed in RedactTypes in every format.
+const redactedText = "<redacted>"
+
+// redacts returns true if the value is of a type listed in RedactTypes.
+func (s *dumpState) redacts(v reflect.Value) bool {
+	return s.config.RedactTypes != nil && v.IsValid() && s.isRedacted(v.Type())
+}
+
+// masks returns true if the value is a string masked by MaskStrings or the `litter:"mask"` tag.
+func (s *dumpState) masks(v reflect.Value) bool {
+	return v.Kind() == reflect.String && (s.config.MaskStrings || s.maskStrings)
+}
+
+// leafText returns the text of a value that the formats other than Go write as a whole, whatever
+// its kind, as dumpVal does: values beyond MaxRecursionDepth are elided, values of RedactTypes are
+// written as <redacted>, masked strings as their length, like string(len=11), collections beyond
+// MaxDepth or longer than SummarizeAbove as their type, and values taken over by DumpFunc,
+// DumpContextFunc or a custom dumper as their compact Go dump. Returns false for other values, which
+// the formats render themselves.
+func (s *dumpState) leafText(v reflect.Value) (string, bool) {
+	mapKey := s.mapKey
+	s.mapKey = reflect.Value{}
+	if s.recursion > s.config.maxRecursionDepth() {
+		s.truncated("MaxRecursionDepth")
+		return "<max recursion exceeded>", true
+	}
+	if s.redacts(v) {
+		return redactedText, true
+	}
+	if s.masks(v) {
+		return fmt.Sprintf("%s(len=%d)", s.typeName(v.Type()), v.Len()), true
+	}
+	if !v.IsValid() || v.Kind() == reflect.Ptr && v.IsNil() {
+		return "", false
+	}
+	// The formats have no indentation to go by, so the depth is that of the path.
+	if s.config.MaxDepth > 0 && len(s.path) >= s.config.MaxDepth && isCompositeKind(v.Kind()) && !isEmptyValue(v) {
+		s.truncated("MaxDepth")
+		return s.typeName(v.Type()) + "{...}", true
+	}
+	if text, ok := s.dumpFuncText(v, mapKey); ok {
+		return text, true
+	}
+	// Pointers to values with custom dumpers are followed by the formats, which then find the values.
+	t := v.Type()
+	if isCustomDumper(t) && !(t.Kind() == reflect.Ptr && isCustomDumper(t.Elem())) {
+		if v := exposeUnexported(v); usesCustomDumper(v) {
+			return s.compactString(v), true
+		}
+	}
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice:
+		if s.config.SummarizeAbove > 0 && v.Len() > s.config.SummarizeAbove {
+			s.truncated("SummarizeAbove")
+			return fmt.Sprintf("%s(len=%d)", s.typeName(t), v.Len()), true
+		}
+	}
+	return "", false
+}
+
+// dumpFuncText returns the compact Go dump of a value taken over by DumpFunc or DumpContextFunc,
+// calling each func once. The map key is valid if the value is one.
+func (s *dumpState) dumpFuncText(v reflect.Value, mapKey reflect.Value) (string, bool) {
+	buf := new(bytes.Buffer)
+	dumped := s.config.DumpFunc != nil && s.config.DumpFunc(v, buf)
+	if !dumped && s.config.DumpContextFunc != nil {
+		buf.Reset()
+		dumped = s.config.DumpContextFunc(s.dumpContext(mapKey), v, buf)
+	}
+	if !dumped {
+		return "", false
+	}
+	out := new(bytes.Buffer)
+	s.compactState(v, out).dumpCustom(v, buf)
+	return out.String(), true
+}
+
+// parentPath returns the path of the value if it is being dumped already, as the formats other than
+// Go write circular references.
+func (s *dumpState) parentPath(v reflect.Value) (string, bool) {
+	path, ok := s.parentPaths[ptrkeyFor(v)]
+	return path, ok
+}
+
+// enterParent records that the value of the pointer, map or slice is being dumped at the given path,
+// until the returned function is called.
+func (s *dumpState) enterParent(v reflect.Value, path string) func() {
+	if s.parentPaths == nil {
+		s.parentPaths = make(map[ptrkey]string)
+	}
+	key := ptrkeyFor(v)
+	s.parentPaths[key] = path
+	return func() { delete(s.parentPaths, key) }
+}
+
+// keyString returns the text of a map key in the formats other than Go: its leaf text, or otherwise
+// its compact Go dump.
+func (s *dumpState) keyString(key reflect.Value) string {
+	s.mapKey = key
+	if text, ok := s.leafText(deInterface(key)); ok {
+		return text
+	}
+	return s.compactString(key)
+}
@@ -0,0 +1,64 @@
+package litter
+
+import (
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"unsafe"
+)
+
+var byteType = reflect.TypeOf(byte(0))
+
+// isHexDumped returns true if structs of the type are listed in HexDumpStructs and have a fixed
+// layout, so that their memory holds all of their content.
+func (s *dumpState) isHexDumped(t reflect.Type) bool {
+	for _, hexDumped := range s.config.HexDumpStructs {
+		if hexDumped == t {
+			return hasFixedLayout(t)
+		}
+	}
+	return false
+}
+
+// hasFixedLayout returns true if values of the type are made of booleans and numbers only, without
+// any pointers, like the headers of binary protocols.
+func hasFixedLayout(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return true
+	case reflect.Array:
+		return hasFixedLayout(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if !hasFixedLayout(t.Field(i).Type) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// dumpHexDump follows a struct with a hexdump of its memory, with offsets, bytes in hex and as ASCII.
+func (s *dumpState) dumpHexDump(v reflect.Value) {
+	if !v.CanAddr() {
+		if !v.CanInterface() {
+			return
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		v = c
+	}
+	raw := reflect.NewAt(reflect.ArrayOf(int(v.Type().Size()), byteType), unsafe.Pointer(v.UnsafeAddr())).Elem()
+	b := make([]byte, raw.Len())
+	reflect.Copy(reflect.ValueOf(b), raw)
+
+	if s.config.Compact {
+		s.inlineComment("hexdump: " + hex.EncodeToString(b))
+		return
+	}
+	s.blockComment("hexdump:", strings.Split(strings.TrimSuffix(hex.Dump(b), "\n"), "\n"))
+}
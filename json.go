@@ -0,0 +1,26 @@
+package litter
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+)
+
+var (
+	jsonNumberType   = reflect.TypeOf(json.Number(""))
+	jsonNumberRegexp = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+)
+
+// dumpJSONNumber dumps a json.Number as the bare number it holds, followed by its type as a comment
+// if ShowScalarTypes is set. Numbers that are not valid JSON are dumped as strings.
+func dumpJSONNumber(s *dumpState, value reflect.Value) bool {
+	v := deInterface(value)
+	if v.Type() != jsonNumberType || !jsonNumberRegexp.MatchString(v.String()) {
+		return false
+	}
+	s.writeString(v.String())
+	if s.config.ShowScalarTypes {
+		s.inlineComment(s.typeName(jsonNumberType))
+	}
+	return true
+}
@@ -0,0 +1,137 @@
+package litter
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dumpLogfmt dumps the fields of a struct as space separated key=value pairs, as used by logfmt.
+// Nested structs and maps with string keys are flattened with dotted keys, like user.name="bob" or
+// user.labels.Env="prod": field names are lowercased, map keys are kept as they are. Values that are
+// neither are dumped as a single pair keyed "value". A value containing itself through a pointer or
+// a map is written as a reference to the key it was first found at.
+func (s *dumpState) dumpLogfmt(value reflect.Value) {
+	first := true
+	s.logfmtNode(value, &first)
+}
+
+// logfmtNode writes the pairs of a value, flattening structs into their fields and maps into their
+// entries.
+func (s *dumpState) logfmtNode(value reflect.Value, first *bool) {
+	s.recursion++
+	defer func() { s.recursion-- }()
+	v := deInterface(value)
+	for {
+		if text, ok := s.leafText(v); ok {
+			s.logfmtPair(strconv.Quote(text), first)
+			return
+		}
+		if v.Kind() != reflect.Ptr && v.Kind() != reflect.Map || v.IsNil() {
+			break
+		}
+		if key, ok := s.parentPath(v); ok {
+			s.logfmtPair(strconv.Quote("<circular reference to "+key+">"), first)
+			return
+		}
+		defer s.enterParent(v, s.logfmtKey())()
+		if v.Kind() != reflect.Ptr {
+			break
+		}
+		v = deInterface(v.Elem())
+	}
+
+	if v.Kind() == reflect.Struct && v.Type() != timeType {
+		for _, i := range s.visibleFields(v) {
+			s.inField(v, i, func(field reflect.Value) {
+				s.logfmtNode(field, first)
+			})
+		}
+		return
+	}
+	if keys, ok := s.logfmtMapKeys(v); ok {
+		for _, key := range keys {
+			s.pushKey(key)
+			s.logfmtNode(v.MapIndex(key), first)
+			s.popPath()
+		}
+		return
+	}
+	s.logfmtPair(s.logfmtValue(v), first)
+}
+
+// logfmtMapKeys returns the keys of the entries of a map that is flattened: a non-empty map with
+// string keys that can be written in logfmt keys as they are.
+func (s *dumpState) logfmtMapKeys(v reflect.Value) ([]reflect.Value, bool) {
+	if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+		return nil, false
+	}
+	keys, _ := s.dumpedMapKeys(v)
+	if len(keys) == 0 {
+		return nil, false
+	}
+	for _, key := range keys {
+		if strings.ContainsAny(s.logfmtMapKey(pathElement{key: key, masked: s.maskStrings}), " =\"") {
+			return nil, false
+		}
+	}
+	return keys, true
+}
+
+// logfmtPair writes a key=value pair for the value at the current path.
+func (s *dumpState) logfmtPair(text string, first *bool) {
+	if !*first {
+		s.writeString(" ")
+	}
+	*first = false
+	s.writeString(s.logfmtKey() + "=" + text)
+}
+
+// logfmtKey returns the key of the value at the current path, or "value" for the dumped value
+// itself.
+func (s *dumpState) logfmtKey() string {
+	if len(s.path) == 0 {
+		return "value"
+	}
+	parts := make([]string, len(s.path))
+	for i, e := range s.path {
+		if e.key.IsValid() {
+			parts[i] = s.logfmtMapKey(e)
+		} else {
+			parts[i] = strings.ToLower(e.field)
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// logfmtMapKey returns the text of a map key in logfmt keys: the key itself for strings, unless it
+// is masked or redacted as in paths.
+func (s *dumpState) logfmtMapKey(e pathElement) string {
+	text := s.pathKeyString(e)
+	if unquoted, err := strconv.Unquote(text); err == nil {
+		return unquoted
+	}
+	return text
+}
+
+// logfmtValue returns the text of a value: booleans and numbers as is, strings and times quoted, and
+// other values as their quoted compact dump.
+func (s *dumpState) logfmtValue(v reflect.Value) string {
+	if !v.IsValid() || isPointerValue(v) && v.IsNil() || v.Kind() == reflect.Interface {
+		return "nil"
+	}
+	switch v.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return s.compactString(v)
+	case reflect.String:
+		return strconv.Quote(v.String())
+	case reflect.Struct:
+		if v.Type() == timeType && v.CanInterface() {
+			return strconv.Quote(v.Interface().(time.Time).Format(time.RFC3339Nano))
+		}
+	}
+	return strconv.Quote(s.compactString(v))
+}
@@ -0,0 +1,146 @@
+package litter
+
+import (
+	"strconv"
+	"strings"
+)
+
+// NormalizeDump canonicalizes the layout of a dump, so that dumps of equal values compare equal
+// regardless of layout options such as Compact. Whitespace outside strings is removed, comments are
+// rewritten as /*comment*/, and trailing commas before closing brackets are dropped. Pointer labels
+// like p3 are renumbered in the order they are defined, so dumps labelling the same pointers
+// differently compare equal.
+func NormalizeDump(dump string) string {
+	out := make([]byte, 0, len(dump))
+	lastComma := -1
+	for i := 0; i < len(dump); i++ {
+		c := dump[i]
+		switch {
+		case c == '"':
+			end := i + 1
+			for end < len(dump) && dump[end] != '"' {
+				if dump[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(dump) {
+				end = len(dump) - 1
+			}
+			out = append(out, dump[i:end+1]...)
+			i = end
+			lastComma = -1
+		case strings.HasPrefix(dump[i:], "//"):
+			end := strings.IndexByte(dump[i:], '\n')
+			if end < 0 {
+				end = len(dump) - i
+			}
+			out = append(out, "/*"+strings.TrimSpace(dump[i+2:i+end])+"*/"...)
+			i += end
+		case strings.HasPrefix(dump[i:], "/*"):
+			end := strings.Index(dump[i+2:], "*/")
+			if end < 0 {
+				end = len(dump) - i - 2
+			}
+			out = append(out, "/*"+strings.TrimSpace(dump[i+2:i+2+end])+"*/"...)
+			i += end + 3
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+		case c == ',':
+			out = append(out, c)
+			lastComma = len(out) - 1
+		case c == '}' || c == ')' || c == ']':
+			if lastComma >= 0 {
+				out = append(out[:lastComma], out[lastComma+1:]...)
+			}
+			out = append(out, c)
+			lastComma = -1
+		default:
+			out = append(out, c)
+			lastComma = -1
+		}
+	}
+	return normalizeLabels(string(out))
+}
+
+// normalizeLabels renumbers the pointer labels of a dump, defined by a /*p0*/ comment or a p0 :=
+// preamble, from zero for each prefix. Identifiers that are not labels, like the names of types
+// and fields, are kept as they are.
+func normalizeLabels(dump string) string {
+	names := map[string]string{}
+	counts := map[string]int{}
+	scanLabels(dump, func(label string, definition bool) string {
+		if _, ok := names[label]; definition && !ok {
+			prefix := strings.TrimRight(label, "0123456789")
+			names[label] = prefix + strconv.Itoa(counts[prefix])
+			counts[prefix]++
+		}
+		return label
+	})
+	return scanLabels(dump, func(label string, definition bool) string {
+		if name, ok := names[label]; ok {
+			return name
+		}
+		return label
+	})
+}
+
+// scanLabels returns the dump with each identifier that may be a label replaced by rename. An
+// identifier may be a label when it is a whole comment or a value on its own, and not part of a
+// qualified name, a type, or a field key.
+func scanLabels(dump string, rename func(label string, definition bool) string) string {
+	out := new(strings.Builder)
+	for i := 0; i < len(dump); {
+		start := i
+		switch c := dump[i]; {
+		case c == '"':
+			i = stringEnd([]byte(dump), i)
+			out.WriteString(dump[start:i])
+		case strings.HasPrefix(dump[i:], "/*"):
+			i = commentEnd([]byte(dump), i, "*/")
+			if text := strings.TrimSuffix(dump[start+2:i], "*/"); isLabel(text) {
+				out.WriteString("/*" + rename(text, true) + "*/")
+			} else {
+				out.WriteString(dump[start:i])
+			}
+		case isIdentByte(c) && !isDigit(c):
+			for i < len(dump) && isIdentByte(dump[i]) {
+				i++
+			}
+			label := dump[start:i]
+			definition := strings.HasPrefix(dump[i:], ":=")
+			qualified := start > 0 && dump[start-1] == '.'
+			if !isLabel(label) || qualified || i < len(dump) && strings.IndexByte(".({[:", dump[i]) >= 0 && !definition {
+				out.WriteString(label)
+			} else {
+				out.WriteString(rename(label, definition))
+			}
+		case isDigit(c):
+			i = numberEnd([]byte(dump), i)
+			out.WriteString(dump[start:i])
+		default:
+			i++
+			out.WriteByte(c)
+		}
+	}
+	return out.String()
+}
+
+// isLabel returns true if text has the form of a pointer label: an identifier ending in digits.
+func isLabel(text string) bool {
+	prefix := strings.TrimRight(text, "0123456789")
+	if prefix == "" || prefix == text || isDigit(prefix[0]) {
+		return false
+	}
+	for i := 0; i < len(prefix); i++ {
+		if !isIdentByte(prefix[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// DumpsEqual returns true if the two dumps are equal after normalizing their layout with
+// NormalizeDump.
+func DumpsEqual(a, b string) bool {
+	return NormalizeDump(a) == NormalizeDump(b)
+}
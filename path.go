@@ -0,0 +1,111 @@
+package litter
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// pathElement is a single step on the way from the dumped root value to the value currently
+// being dumped: a struct field, a slice or array index, or a map key.
+type pathElement struct {
+	field string
+	index int
+	key   reflect.Value
+
+	// masked is true if the key is a string masked by the `litter:"mask"` tag.
+	masked bool
+}
+
+func (s *dumpState) pushField(name string) {
+	s.path = append(s.path, pathElement{field: name})
+}
+
+func (s *dumpState) pushIndex(i int) {
+	s.path = append(s.path, pathElement{index: i})
+}
+
+func (s *dumpState) pushKey(key reflect.Value) {
+	s.path = append(s.path, pathElement{key: key, masked: s.maskStrings})
+}
+
+func (s *dumpState) popPath() {
+	s.path = s.path[:len(s.path)-1]
+}
+
+// pathString renders the current path, e.g. `Users[0].Credentials.Password` or `Settings["db"]`.
+func (s *dumpState) pathString() string {
+	return s.formatPath(s.path)
+}
+
+func (s *dumpState) formatPath(path []pathElement) string {
+	var b strings.Builder
+	for _, e := range path {
+		switch {
+		case e.field != "":
+			if b.Len() > 0 {
+				b.WriteString(".")
+			}
+			b.WriteString(e.field)
+		case e.key.IsValid():
+			b.WriteString("[")
+			b.WriteString(s.pathKeyString(e))
+			b.WriteString("]")
+		default:
+			b.WriteString("[")
+			b.WriteString(strconv.Itoa(e.index))
+			b.WriteString("]")
+		}
+	}
+	return b.String()
+}
+
+// pathKeyString dumps the map key of a path element in compact form, ignoring the options other
+// than RedactTypes and the masking of strings, so that paths have the same syntax regardless of the
+// options without revealing the content of keys.
+func (s *dumpState) pathKeyString(e pathElement) string {
+	buf := new(bytes.Buffer)
+	opts := Options{
+		Compact:     true,
+		RedactTypes: s.config.RedactTypes,
+		MaskStrings: s.config.MaskStrings || e.masked,
+	}
+	newDumpState(e.key, &opts, buf).dumpVal(e.key)
+	return buf.String()
+}
+
+// compactString dumps a value on its own, in compact form, using the current options, masking and
+// path.
+func (s *dumpState) compactString(v reflect.Value) string {
+	buf := new(bytes.Buffer)
+	s.compactState(v, buf).dumpVal(v)
+	return buf.String()
+}
+
+// compactState returns a state for dumping a value on its own to w, in compact form, using the
+// current options, masking and path.
+func (s *dumpState) compactState(v reflect.Value, w io.Writer) *dumpState {
+	opts := *s.config
+	opts.Compact = true
+	state := newDumpState(v, &opts, w)
+	state.maskStrings = s.maskStrings
+	state.path = append([]pathElement(nil), s.path...)
+	return state
+}
+
+// isPathExcluded returns true if the field with the given name, relative to the current path, is
+// listed in PathExclusions.
+func (s *dumpState) isPathExcluded(field string) bool {
+	if len(s.config.PathExclusions) == 0 {
+		return false
+	}
+	path := s.formatPath(append(s.path[:len(s.path):len(s.path)], pathElement{field: field}))
+	for _, excluded := range s.config.PathExclusions {
+		if path == excluded {
+			return true
+		}
+	}
+	return false
+}
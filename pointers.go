@@ -8,34 +8,48 @@ import (
 
 // mapReusedPointers takes a structure, and recursively maps all pointers mentioned in the tree,
 // detecting circular references, and providing a list of all pointers that was referenced at
-// least twice by the provided structure.
-func mapReusedPointers(v reflect.Value) ptrmap {
+// least twice by the provided structure. If InternStrings is set, strings that occur at least
+// twice are mapped as well.
+func mapReusedPointers(v reflect.Value, options *Options) (*ptrmap, *stringmap) {
 	pm := &pointerVisitor{}
-	pm.consider(v)
-	return pm.reused
+	if options.InternStrings {
+		pm.strings = make(map[string]int)
+		pm.minStringLength = options.MinInternLength
+	}
+	pm.maxDepth = options.MaxDepth
+	pm.maxRecursion = options.maxRecursionDepth()
+	pm.containers = options.dumpsContainers()
+	pm.consider(v, 0)
+	return &pm.reused, pm.reusedStrings()
 }
 
 // A map of pointers.
 type ptrinfo struct {
 	id     int
 	parent *ptrmap
+	refs   int
 }
 
-func (p *ptrinfo) label() string {
+func (p *ptrinfo) label(prefix string) string {
 	if p.id == -1 {
 		p.id = p.parent.count
 		p.parent.count++
 	}
-	return fmt.Sprintf("p%d", p.id)
+	return fmt.Sprintf("%s%d", prefix, p.id)
 }
 
 type ptrkey struct {
 	p uintptr
 	t reflect.Type
+	n int
 }
 
 func ptrkeyFor(v reflect.Value) (k ptrkey) {
 	k.p = v.Pointer()
+	// Slices of different lengths starting at the same element are different values.
+	if v.Kind() == reflect.Slice {
+		k.n = v.Len()
+	}
 	for v.Kind() == reflect.Ptr {
 		v = v.Elem()
 	}
@@ -84,29 +98,124 @@ func (pm *ptrmap) add(p reflect.Value) bool {
 	return true
 }
 
-// Adds a pointer (slow path).
-func (pm *ptrmap) put(v reflect.Value) {
+// Returns a copy of the map, sharing the pointer information.
+func (pm *ptrmap) clone() ptrmap {
+	result := ptrmap{count: pm.count}
+	if pm.m != nil {
+		result.m = make(map[ptrkey]*ptrinfo, len(pm.m))
+		for k, v := range pm.m {
+			result.m[k] = v
+		}
+	}
+	return result
+}
+
+// Forgets the labels assigned since the count was the given value.
+func (pm *ptrmap) resetLabels(count int) {
+	for _, info := range pm.m {
+		if info.id >= count {
+			info.id = -1
+		}
+	}
+	pm.count = count
+}
+
+// Adds a pointer (slow path), returning its information.
+func (pm *ptrmap) put(v reflect.Value) *ptrinfo {
 	if pm.m == nil {
 		pm.m = make(map[ptrkey]*ptrinfo, 31)
 	}
 
 	key := ptrkeyFor(v)
-	if _, ok := pm.m[key]; !ok {
-		pm.m[key] = &ptrinfo{id: -1, parent: pm}
+	info, ok := pm.m[key]
+	if !ok {
+		info = &ptrinfo{id: -1, parent: pm}
+		pm.m[key] = info
+	}
+	return info
+}
+
+// A map of strings occurring more than once.
+type stringmap struct {
+	m       map[string]*ptrinfo
+	visited map[string]bool
+	labels  ptrmap
+}
+
+// Gets a string, returning true if it is being visited for the first time.
+func (sm *stringmap) visit(str string) (*ptrinfo, bool) {
+	info, ok := sm.m[str]
+	if !ok {
+		return nil, false
+	}
+	if sm.visited[str] {
+		return info, false
+	}
+	sm.visited[str] = true
+	return info, true
+}
+
+// Returns a copy of the visited strings and the label count, for restoring with rollback.
+func (sm *stringmap) snapshot() (map[string]bool, int) {
+	visited := make(map[string]bool, len(sm.visited))
+	for str := range sm.visited {
+		visited[str] = true
+	}
+	return visited, sm.labels.count
+}
+
+// Restores the visited strings and labels from a snapshot.
+func (sm *stringmap) rollback(visited map[string]bool, count int) {
+	sm.visited = visited
+	for _, info := range sm.m {
+		if info.id >= count {
+			info.id = -1
+		}
 	}
+	sm.labels.count = count
 }
 
 type pointerVisitor struct {
-	pointers ptrmap
-	reused   ptrmap
+	pointers        ptrmap
+	reused          ptrmap
+	strings         map[string]int
+	minStringLength int
+	maxDepth        int
+	maxRecursion    int
+	recursion       int
+
+	// containers is true if the containers of container/list and container/ring are dumped as their
+	// elements, which are then considered instead of the internals of the containers.
+	containers bool
+}
+
+// Returns the strings seen more than once.
+func (pv *pointerVisitor) reusedStrings() *stringmap {
+	if pv.strings == nil {
+		return &stringmap{}
+	}
+	sm := &stringmap{
+		m:       make(map[string]*ptrinfo),
+		visited: make(map[string]bool),
+	}
+	for str, count := range pv.strings {
+		if count > 1 {
+			sm.m[str] = &ptrinfo{id: -1, parent: &sm.labels}
+		}
+	}
+	return sm
 }
 
 // Recursively consider v and each of its children, updating the map according to the
-// semantics of MapReusedPointers
-func (pv *pointerVisitor) consider(v reflect.Value) {
-	if v.Kind() == reflect.Invalid {
+// semantics of MapReusedPointers. The depth is the nesting depth of v in structs, slices and maps,
+// which stops the descent at the same depth as MaxDepth stops the dump.
+func (pv *pointerVisitor) consider(v reflect.Value, depth int) {
+	if v.Kind() == reflect.Invalid || pv.recursion >= pv.maxRecursion {
 		return
 	}
+	pv.recursion++
+	defer func() { pv.recursion-- }()
+
 	if isPointerValue(v) { // pointer is 0 for unexported fields
 		if pv.tryAddPointer(v) {
 			// No use descending inside this value, since it have been seen before and all its descendants
@@ -115,18 +224,31 @@ func (pv *pointerVisitor) consider(v reflect.Value) {
 		}
 	}
 
+	if pv.maxDepth > 0 && depth >= pv.maxDepth && isCompositeKind(v.Kind()) {
+		return
+	}
+
+	if pv.containers {
+		if elements, ok := containerElements(v); ok {
+			for _, element := range elements {
+				pv.consider(element, depth+1)
+			}
+			return
+		}
+	}
+
 	// Now descend into any children of this value
 	switch v.Kind() {
 	case reflect.Slice, reflect.Array:
 		for i := 0; i < v.Len(); i++ {
-			pv.consider(v.Index(i))
+			pv.consider(v.Index(i), depth+1)
 		}
 
 	case reflect.Interface:
-		pv.consider(v.Elem())
+		pv.consider(v.Elem(), depth)
 
 	case reflect.Ptr:
-		pv.consider(v.Elem())
+		pv.consider(v.Elem(), depth)
 
 	case reflect.Map:
 		keys := v.MapKeys()
@@ -135,33 +257,35 @@ func (pv *pointerVisitor) consider(v reflect.Value) {
 			options: &Config,
 		})
 		for _, key := range keys {
-			pv.consider(key)
-			pv.consider(v.MapIndex(key))
+			pv.consider(key, depth+1)
+			pv.consider(v.MapIndex(key), depth+1)
+		}
+
+	case reflect.String:
+		if pv.strings != nil && v.Len() >= pv.minStringLength {
+			pv.strings[v.String()]++
 		}
 
 	case reflect.Struct:
 		numFields := v.NumField()
 		for i := 0; i < numFields; i++ {
-			pv.consider(v.Field(i))
+			pv.consider(v.Field(i), depth+1)
 		}
 	}
 }
 
-// addPointer to the pointerMap, update reusedPointers. Returns true if pointer was reused
+// addPointer to the pointerMap, update reusedPointers and count the reference. Returns true if
+// pointer was reused.
 func (pv *pointerVisitor) tryAddPointer(v reflect.Value) bool {
-	// Is this allready known to be reused?
-	if pv.reused.contains(v) {
-		return true
-	}
+	info := pv.pointers.put(v)
+	info.refs++
 
-	// Have we seen it once before?
-	if pv.pointers.contains(v) {
-		// Add it to the register of pointers we have seen more than once
-		pv.reused.add(v)
-		return true
+	// This pointer was new to us
+	if info.refs == 1 {
+		return false
 	}
 
-	// This pointer was new to us
-	pv.pointers.add(v)
-	return false
+	// Add it to the register of pointers we have seen more than once
+	pv.reused.add(v)
+	return true
 }
@@ -8,43 +8,88 @@ import (
 
 func printBool(w io.Writer, value bool) {
 	if value {
-		w.Write([]byte("true"))
+		io.WriteString(w, "true")
 		return
 	}
-	w.Write([]byte("false"))
+	io.WriteString(w, "false")
 }
 
 func printInt(w io.Writer, val int64, base int) {
-	w.Write([]byte(strconv.FormatInt(val, base)))
+	io.WriteString(w, strconv.FormatInt(val, base))
 }
 
 func printUint(w io.Writer, val uint64, base int) {
-	w.Write([]byte(strconv.FormatUint(val, base)))
+	io.WriteString(w, strconv.FormatUint(val, base))
+}
+
+// groupDigits inserts thousands separators into a formatted integer, like 1,000,000.
+func groupDigits(digits string) string {
+	sign := ""
+	if len(digits) > 0 && digits[0] == '-' {
+		sign, digits = "-", digits[1:]
+	}
+	if len(digits) <= 3 {
+		return sign + digits
+	}
+	grouped := make([]byte, 0, len(digits)+len(digits)/3)
+	for i := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			grouped = append(grouped, ',')
+		}
+		grouped = append(grouped, digits[i])
+	}
+	return sign + string(grouped)
 }
 
 func printFloat(w io.Writer, val float64, precision int) {
 	if math.Trunc(val) == val {
 		// Ensure that floats like 1.0 are always printed with a decimal point
-		w.Write([]byte(strconv.FormatFloat(val, 'f', 1, precision)))
+		io.WriteString(w, strconv.FormatFloat(val, 'f', 1, precision))
 	} else {
-		w.Write([]byte(strconv.FormatFloat(val, 'g', -1, precision)))
+		io.WriteString(w, strconv.FormatFloat(val, 'g', -1, precision))
 	}
 }
 
 func printComplex(w io.Writer, c complex128, floatPrecision int) {
-	w.Write([]byte("complex"))
+	io.WriteString(w, "complex")
 	printInt(w, int64(floatPrecision*2), 10)
 	r := real(c)
-	w.Write([]byte("("))
-	w.Write([]byte(strconv.FormatFloat(r, 'g', -1, floatPrecision)))
 	i := imag(c)
-	if i >= 0 {
-		w.Write([]byte("+"))
+	if !isFinite(r) || !isFinite(i) {
+		// Special values cannot be written as a complex literal, so use the complex builtin
+		io.WriteString(w, "(complex(")
+		printFloatComponent(w, r, floatPrecision)
+		io.WriteString(w, ", ")
+		printFloatComponent(w, i, floatPrecision)
+		io.WriteString(w, "))")
+		return
 	}
-	w.Write([]byte(strconv.FormatFloat(i, 'g', -1, floatPrecision)))
-	w.Write([]byte("i)"))
+	io.WriteString(w, "(")
+	io.WriteString(w, strconv.FormatFloat(r, 'g', -1, floatPrecision))
+	if !math.Signbit(i) {
+		io.WriteString(w, "+")
+	}
+	io.WriteString(w, strconv.FormatFloat(i, 'g', -1, floatPrecision))
+	io.WriteString(w, "i)")
+}
+
+func printFloatComponent(w io.Writer, val float64, precision int) {
+	switch {
+	case math.IsNaN(val):
+		io.WriteString(w, "math.NaN()")
+	case math.IsInf(val, 1):
+		io.WriteString(w, "math.Inf(1)")
+	case math.IsInf(val, -1):
+		io.WriteString(w, "math.Inf(-1)")
+	default:
+		io.WriteString(w, strconv.FormatFloat(val, 'g', -1, precision))
+	}
+}
+
+func isFinite(val float64) bool {
+	return !math.IsNaN(val) && !math.IsInf(val, 0)
 }
 
 func printNil(w io.Writer) {
-	w.Write([]byte("nil"))
+	io.WriteString(w, "nil")
 }
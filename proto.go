@@ -0,0 +1,56 @@
+package litter
+
+import (
+	"reflect"
+	"strings"
+)
+
+// protoMessage is implemented by messages generated by protoc-gen-go. It is matched structurally to
+// avoid depending on the protobuf module.
+type protoMessage interface {
+	ProtoMessage()
+	String() string
+}
+
+// protoEnum is implemented by enums generated by protoc-gen-go.
+type protoEnum interface {
+	String() string
+	EnumDescriptor() ([]byte, []int)
+}
+
+var (
+	protoMessageType = reflect.TypeOf((*protoMessage)(nil)).Elem()
+	protoEnumType    = reflect.TypeOf((*protoEnum)(nil)).Elem()
+)
+
+// dumpProto dumps protobuf messages without their generated internals, showing their text format
+// in a comment, and protobuf enums with their names. Enabled by ProtoText. With StrictGo, messages
+// are dumped as structs, since their text format cannot be pasted as Go.
+func dumpProto(s *dumpState, value reflect.Value) bool {
+	if !s.config.ProtoText {
+		return false
+	}
+	v := deInterface(value)
+	if !v.CanInterface() || v.Kind() == reflect.Ptr && v.IsNil() {
+		return false
+	}
+	switch {
+	case v.Type().Implements(protoMessageType) && v.Kind() == reflect.Ptr && !s.config.StrictGo:
+		s.writeString("&")
+		s.writeString(s.typeName(v.Type().Elem()))
+		s.writeString("{}")
+		text := strings.TrimSpace(strings.Replace(v.Interface().(protoMessage).String(), "\n", " ", -1))
+		if text != "" {
+			s.inlineComment(strings.Replace(text, "*/", "* /", -1))
+		}
+		return true
+	case v.Type().Implements(protoEnumType) && v.Kind() == reflect.Int32:
+		s.dumpType(v)
+		s.writeString("(")
+		printInt(s, v.Int(), 10)
+		s.writeString(")")
+		s.inlineComment(v.Interface().(protoEnum).String())
+		return true
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package litter
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"sort"
+)
+
+// Region is the range of bytes of a dump written for a value, such as a struct field or a slice
+// element, so that tools like dump viewers can map the text back to the values it was made from.
+type Region struct {
+	// Value is the value as found when dumping, possibly of an interface type.
+	Value reflect.Value
+
+	// Path is the path of the value from the dumped value, like "Users[0].Name".
+	Path string
+
+	// Start and End are the offsets of the first byte of the value and of the byte after the last.
+	Start, End int
+}
+
+// regionRecorder records the regions of the values written to w, which writes to buf.
+type regionRecorder struct {
+	regions []Region
+	buf     *bytes.Buffer
+	w       io.Writer
+}
+
+// recordRegion records the region of a value that started at the given offset and ends at the
+// current end of the output.
+func (s *dumpState) recordRegion(value reflect.Value, start int) {
+	s.regions.regions = append(s.regions.regions, Region{
+		Value: value,
+		Path:  s.pathString(),
+		Start: start,
+		End:   s.regions.buf.Len(),
+	})
+}
+
+// SdumpWithRegions dumps the values to a string like Sdump, and returns the regions of the values
+// making up the dump, ordered by their start, enclosing regions first. The dumped values themselves
+// have the empty path. Regions are only recorded for the Go syntax, and not with Gofmt, which
+// reformats the output, nor for the cells of TabularSlices.
+func (o Options) SdumpWithRegions(values ...interface{}) (string, []Region) {
+	buf := new(bytes.Buffer)
+	regions := &regionRecorder{buf: buf}
+	_ = fdump(&o, buf, nil, regions, values)
+	sort.SliceStable(regions.regions, func(i, j int) bool {
+		a, b := regions.regions[i], regions.regions[j]
+		if a.Start != b.Start {
+			return a.Start < b.Start
+		}
+		return a.End > b.End
+	})
+	return buf.String(), regions.regions
+}
@@ -0,0 +1,163 @@
+package litter
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dumpRepr dumps the value in the style of Python's repr.
+func (s *dumpState) dumpRepr(value reflect.Value) {
+	s.recursion++
+	defer func() { s.recursion-- }()
+	v := deInterface(value)
+	for {
+		if text, ok := s.leafText(v); ok {
+			s.writeString(reprQuote(text))
+			return
+		}
+		if !isPointerValue(v) {
+			break
+		}
+		if v.IsNil() {
+			s.writeString("None")
+			return
+		}
+		if !s.parentPointers.add(v) {
+			s.reprCycle(v)
+			return
+		}
+		defer s.parentPointers.remove(v)
+		if v.Kind() != reflect.Ptr {
+			break
+		}
+		v = deInterface(v.Elem())
+	}
+
+	if !v.IsValid() || v.Kind() == reflect.Interface {
+		s.writeString("None")
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			s.writeString("True")
+		} else {
+			s.writeString("False")
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s.writeString(strconv.FormatInt(v.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		s.writeString(strconv.FormatUint(v.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		s.writeString(reprFloat(v.Float()))
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		s.writeString("complex(" + reprFloat(real(c)) + ", " + reprFloat(imag(c)) + ")")
+	case reflect.String:
+		s.writeString(reprQuote(v.String()))
+	case reflect.Slice, reflect.Array:
+		s.writeString("[")
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				s.writeString(", ")
+			}
+			s.pushIndex(i)
+			s.dumpRepr(v.Index(i))
+			s.popPath()
+		}
+		s.writeString("]")
+	case reflect.Map:
+		s.writeString("{")
+		keys, omitted := s.dumpedMapKeys(v)
+		for i, key := range keys {
+			if i > 0 {
+				s.writeString(", ")
+			}
+			s.mapKey = key
+			s.dumpRepr(key)
+			s.writeString(": ")
+			s.pushKey(key)
+			s.dumpRepr(v.MapIndex(key))
+			s.popPath()
+		}
+		if omitted > 0 {
+			s.writeString(", ...")
+		}
+		s.writeString("}")
+	case reflect.Struct:
+		if v.Type() == timeType && v.CanInterface() {
+			s.writeString(reprQuote(v.Interface().(time.Time).Format(time.RFC3339Nano)))
+			return
+		}
+		s.writeString(s.reprClassName(v.Type()) + "(")
+		vt := v.Type()
+		for n, i := range s.visibleFields(v) {
+			if n > 0 {
+				s.writeString(", ")
+			}
+			s.writeString(vt.Field(i).Name + "=")
+			s.inField(v, i, s.dumpRepr)
+		}
+		s.writeString(")")
+	default:
+		s.writeString("<" + s.compactString(v) + ">")
+	}
+}
+
+// reprCycle writes a reference back to a value being dumped, like Python writes recursive lists.
+func (s *dumpState) reprCycle(v reflect.Value) {
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = deInterface(v.Elem())
+	}
+	switch v.Kind() {
+	case reflect.Map:
+		s.writeString("{...}")
+	case reflect.Struct:
+		s.writeString(s.reprClassName(v.Type()) + "(...)")
+	default:
+		s.writeString("[...]")
+	}
+}
+
+// reprClassName returns the name of a struct type, or "struct" for anonymous structs.
+func (s *dumpState) reprClassName(t reflect.Type) string {
+	if t.Name() == "" {
+		return "struct"
+	}
+	return s.typeName(t)
+}
+
+// reprFloat formats a float like Python does, using float('inf') and float('nan') for special
+// values.
+func reprFloat(f float64) string {
+	switch {
+	case isFinite(f):
+		if math.Trunc(f) == f && math.Abs(f) < 1e16 {
+			return strconv.FormatFloat(f, 'f', 1, 64)
+		}
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	case math.IsNaN(f):
+		return "float('nan')"
+	case f > 0:
+		return "float('inf')"
+	default:
+		return "float('-inf')"
+	}
+}
+
+// reprQuote quotes a string like Python does, in single quotes unless the string contains single
+// quotes but no double quotes.
+func reprQuote(str string) string {
+	quoted := strconv.Quote(str)
+	if strings.Contains(str, "'") && !strings.Contains(str, `"`) {
+		return quoted
+	}
+	inner := quoted[1 : len(quoted)-1]
+	inner = strings.Replace(inner, `\"`, `"`, -1)
+	inner = strings.Replace(inner, `'`, `\'`, -1)
+	return "'" + inner + "'"
+}
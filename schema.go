@@ -0,0 +1,105 @@
+package litter
+
+import (
+	"reflect"
+	"strconv"
+)
+
+// dumpSchema dumps the structure of a type instead of a value: the names and types of the fields of
+// structs, recursively, like User{ID int; Addr *Address{Street string}}. Structs already being
+// expanded are written as Name{...}.
+func (s *dumpState) dumpSchema(t reflect.Type) {
+	if t == nil {
+		printNil(s)
+		return
+	}
+	s.schemaType(t, map[reflect.Type]bool{})
+}
+
+// schemaType writes a type, expanding the structs it is made of.
+func (s *dumpState) schemaType(t reflect.Type, expanding map[reflect.Type]bool) {
+	switch t.Kind() {
+	case reflect.Ptr:
+		s.writeString("*")
+		s.schemaType(t.Elem(), expanding)
+		return
+	case reflect.Slice:
+		s.writeString("[]")
+		s.schemaType(t.Elem(), expanding)
+		return
+	case reflect.Array:
+		s.writeString("[" + strconv.Itoa(t.Len()) + "]")
+		s.schemaType(t.Elem(), expanding)
+		return
+	case reflect.Map:
+		s.writeString("map[")
+		s.schemaType(t.Key(), expanding)
+		s.writeString("]")
+		s.schemaType(t.Elem(), expanding)
+		return
+	case reflect.Struct:
+		if t != timeType {
+			s.schemaStruct(t, expanding)
+			return
+		}
+	}
+	s.writeString(s.typeName(t))
+}
+
+// schemaStruct writes a struct type as its name followed by its fields.
+func (s *dumpState) schemaStruct(t reflect.Type, expanding map[reflect.Type]bool) {
+	name := "struct"
+	if t.Name() != "" {
+		name = s.typeName(t)
+	}
+	if expanding[t] {
+		s.writeString(name + "{...}")
+		return
+	}
+	expanding[t] = true
+	defer delete(expanding, t)
+
+	fields := s.schemaFields(t)
+	s.writeString(name + "{")
+	if len(fields) == 0 {
+		s.writeString("}")
+		return
+	}
+	s.newlineWithPointerNameComment()
+	s.depth++
+	for n, f := range fields {
+		s.indent()
+		if !f.Anonymous {
+			s.writeString(f.Name + " ")
+		}
+		s.schemaType(f.Type, expanding)
+		if s.config.Compact && n < len(fields)-1 {
+			s.writeString("; ")
+		}
+		s.newlineWithPointerNameComment()
+	}
+	s.depth--
+	s.indent()
+	s.writeString("}")
+}
+
+// schemaFields returns the fields of the struct type that should be dumped. Filters needing values
+// do not apply.
+func (s *dumpState) schemaFields(t reflect.Type) []reflect.StructField {
+	hidePrivateFields := s.hidePrivateFields(t)
+	var fields []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if s.config.FieldInclusions != nil && !s.config.FieldInclusions.MatchString(f.Name) {
+			continue
+		}
+		if hidePrivateFields && f.PkgPath != "" || s.config.FieldExclusions != nil && s.config.FieldExclusions.MatchString(f.Name) {
+			continue
+		}
+		if s.config.HideFuncFields && f.Type.Kind() == reflect.Func {
+			continue
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
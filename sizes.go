@@ -0,0 +1,93 @@
+package litter
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// dumpSize follows a value with a comment estimating its size in memory, like /* ~4.2 KB */, for
+// ShowSizes.
+func (s *dumpState) dumpSize(v reflect.Value) {
+	if s.sizes == nil {
+		s.sizes = make(map[ptrkey]uintptr)
+	}
+	size := v.Type().Size() + s.indirectSize(v, map[ptrkey]bool{})
+	s.inlineComment("~" + formatSize(size))
+}
+
+// indirectSize estimates the memory referenced by a value, beyond the size of its type: the backing
+// arrays of slices, the entries of maps, the bytes of strings, and the values held by pointers and
+// interfaces. Memory referenced more than once is counted once. Allocator overhead is ignored.
+//
+// The memory referenced by pointers, slices and maps is measured once per dump and then reused, so
+// that nested values, which are measured before the values containing them, are not walked again.
+func (s *dumpState) indirectSize(v reflect.Value, seen map[ptrkey]bool) uintptr {
+	switch v.Kind() {
+	case reflect.String:
+		return uintptr(v.Len())
+	case reflect.Ptr, reflect.Slice, reflect.Map:
+		if v.IsNil() {
+			return 0
+		}
+		key := ptrkeyFor(v)
+		if seen[key] {
+			return 0
+		}
+		seen[key] = true
+		if size, ok := s.sizes[key]; ok {
+			return size
+		}
+		size := s.referencedSize(v, seen)
+		s.sizes[key] = size
+		return size
+	}
+	return s.referencedSize(v, seen)
+}
+
+// referencedSize returns the memory referenced by the value, as indirectSize, without looking it up.
+func (s *dumpState) referencedSize(v reflect.Value, seen map[ptrkey]bool) uintptr {
+	var size uintptr
+	switch v.Kind() {
+	case reflect.Ptr:
+		size = v.Type().Elem().Size() + s.indirectSize(v.Elem(), seen)
+	case reflect.Interface:
+		if !v.IsNil() {
+			size = v.Elem().Type().Size() + s.indirectSize(v.Elem(), seen)
+		}
+	case reflect.Slice:
+		size = uintptr(v.Cap()) * v.Type().Elem().Size()
+		for i := 0; i < v.Len(); i++ {
+			size += s.indirectSize(v.Index(i), seen)
+		}
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			size += s.indirectSize(v.Index(i), seen)
+		}
+	case reflect.Map:
+		size = uintptr(v.Len()) * (v.Type().Key().Size() + v.Type().Elem().Size())
+		iter := v.MapRange()
+		for iter.Next() {
+			size += s.indirectSize(iter.Key(), seen) + s.indirectSize(iter.Value(), seen)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			size += s.indirectSize(v.Field(i), seen)
+		}
+	}
+	return size
+}
+
+// formatSize formats a number of bytes with a binary unit, like 4.2 KB.
+func formatSize(size uintptr) string {
+	if size < 1024 {
+		return fmt.Sprintf("%d B", size)
+	}
+	n := float64(size)
+	for _, unit := range []string{"KB", "MB", "GB"} {
+		n /= 1024
+		if n < 1024 || unit == "GB" {
+			return fmt.Sprintf("%.1f %s", n, unit)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,30 @@
+package litter
+
+import (
+	"reflect"
+	"strings"
+)
+
+// dumpSQLNull dumps the Null types of database/sql, like sql.NullString, as their value if valid,
+// like sql.NullString("x"), and as sql.NullString(nil) otherwise.
+func dumpSQLNull(s *dumpState, value reflect.Value) bool {
+	v := deInterface(value)
+	t := v.Type()
+	if t.Kind() != reflect.Struct || t.PkgPath() != "database/sql" || !strings.HasPrefix(t.Name(), "Null") ||
+		t.NumField() != 2 {
+		return false
+	}
+	valid, ok := t.FieldByName("Valid")
+	if !ok || valid.Index[0] != 1 || valid.Type.Kind() != reflect.Bool {
+		return false
+	}
+	s.dumpType(v)
+	s.writeString("(")
+	if v.Field(1).Bool() {
+		s.dumpVal(v.Field(0))
+	} else {
+		printNil(s)
+	}
+	s.writeString(")")
+	return true
+}
@@ -0,0 +1,55 @@
+package litter
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// stackTrace returns the program counters of the stack trace carried by an error, if it has a
+// StackTrace method returning a slice of uintptr values, like []uintptr or the StackTrace type of
+// github.com/pkg/errors.
+func stackTrace(v reflect.Value) ([]uintptr, bool) {
+	if !v.IsValid() || !v.CanInterface() || !v.Type().Implements(errorType) || isPointerValue(v) && v.IsNil() {
+		return nil, false
+	}
+	method := v.MethodByName("StackTrace")
+	if !method.IsValid() {
+		return nil, false
+	}
+	t := method.Type()
+	if t.NumIn() != 0 || t.NumOut() != 1 || t.Out(0).Kind() != reflect.Slice ||
+		t.Out(0).Elem().Kind() != reflect.Uintptr {
+		return nil, false
+	}
+	frames := method.Call(nil)[0]
+	pcs := make([]uintptr, frames.Len())
+	for i := range pcs {
+		pcs[i] = uintptr(frames.Index(i).Uint())
+	}
+	return pcs, len(pcs) > 0
+}
+
+// dumpStackTrace writes a comment listing the frames of a stack trace, one per line, like
+// main.run (main.go:12), dropping the import path of the package.
+func (s *dumpState) dumpStackTrace(pcs []uintptr) {
+	var lines []string
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		lines = append(lines, fmt.Sprintf("%s (%s:%d)", path.Base(frame.Function), filepath.Base(frame.File), frame.Line))
+		if !more {
+			break
+		}
+	}
+	if s.config.Compact {
+		s.inlineComment("stack: " + strings.Join(lines, "; "))
+		return
+	}
+	s.blockComment("stack:", lines)
+}
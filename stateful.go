@@ -0,0 +1,67 @@
+package litter
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// Stateful dumps values with pointer labels that are consistent across calls, so that an object
+// labelled p0 in one dump is labelled p0 in every later dump as well. Labels are still only shown
+// for pointers referenced more than once within a single dump. A Stateful is not safe for
+// concurrent use.
+type Stateful struct {
+	options Options
+	labels  map[ptrkey]int
+	count   int
+
+	// values keeps the dumped values alive, so the addresses the labels are keyed by are not reused.
+	values []interface{}
+}
+
+// NewStateful returns a Stateful dumping values according to the options.
+func NewStateful(o Options) *Stateful {
+	return &Stateful{
+		options: o,
+		labels:  make(map[ptrkey]int),
+	}
+}
+
+// Dump a value to stdout.
+func (st *Stateful) Dump(values ...interface{}) {
+	_ = st.Fdump(os.Stdout, values...)
+	_, _ = io.WriteString(os.Stdout, "\n")
+}
+
+// Sdump dumps a value to a string.
+func (st *Stateful) Sdump(values ...interface{}) string {
+	buf := new(bytes.Buffer)
+	_ = st.Fdump(buf, values...)
+	return buf.String()
+}
+
+// Fdump dumps a value to a writer, returning the first error from writing.
+func (st *Stateful) Fdump(w io.Writer, values ...interface{}) error {
+	st.values = append(st.values, values...)
+	return fdump(&st.options, w, st, nil, values)
+}
+
+// restore gives the pointers of the state the labels they were given by earlier dumps.
+func (st *Stateful) restore(s *dumpState) {
+	for key, info := range s.pointers.m {
+		if id, ok := st.labels[key]; ok {
+			info.id = id
+		}
+	}
+	s.pointers.count = st.count
+}
+
+// record remembers the labels given by the state.
+func (st *Stateful) record(s *dumpState) {
+	for key, info := range s.pointers.m {
+		if info.id != -1 {
+			st.labels[key] = info.id
+		}
+	}
+	st.count = s.pointers.count
+}
@@ -0,0 +1,41 @@
+package litter
+
+import (
+	"reflect"
+	"sort"
+)
+
+// PointerStat describes a pointer that is referenced more than once by a value.
+type PointerStat struct {
+	// Addr is the address the pointer points to.
+	Addr uintptr
+	// Type is the type of the referenced value.
+	Type reflect.Type
+	// Count is the number of references to the pointer.
+	Count int
+}
+
+// ReusedPointers returns the pointers referenced more than once by the value, such as shared
+// structs and circular references, most referenced first. These are the pointers litter replaces
+// with labels when dumping.
+func ReusedPointers(value interface{}) []PointerStat {
+	pv := &pointerVisitor{maxRecursion: defaultMaxRecursionDepth, containers: true}
+	pv.consider(reflect.ValueOf(value), 0)
+
+	var stats []PointerStat
+	for key, info := range pv.pointers.m {
+		if info.refs > 1 && key.p != 0 {
+			stats = append(stats, PointerStat{Addr: key.p, Type: key.t, Count: info.refs})
+		}
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Count != stats[j].Count {
+			return stats[i].Count > stats[j].Count
+		}
+		if stats[i].Type != stats[j].Type {
+			return stats[i].Type.String() < stats[j].Type.String()
+		}
+		return stats[i].Addr < stats[j].Addr
+	})
+	return stats
+}
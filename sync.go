@@ -0,0 +1,110 @@
+package litter
+
+import (
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"unsafe"
+)
+
+// dumpSync dumps sync.Once, sync.WaitGroup and sync.Mutex as their logical state, like
+// sync.Once{done: true}, rather than as their internals, which differ between Go versions. The state
+// is read with atomic loads. Values whose internals are not recognized are dumped as usual.
+func dumpSync(s *dumpState, value reflect.Value) bool {
+	v := deInterface(value)
+	t := v.Type()
+	if t.Kind() != reflect.Struct || t.PkgPath() != "sync" {
+		return false
+	}
+	if !v.CanAddr() {
+		if !v.CanInterface() {
+			return false
+		}
+		c := reflect.New(t).Elem()
+		c.Set(v)
+		v = c
+	}
+
+	var fields []string
+	switch t.Name() {
+	case "Once":
+		done, ok := loadAtomic(v.FieldByName("done"))
+		if !ok {
+			return false
+		}
+		fields = []string{"done", strconv.FormatBool(done != 0)}
+	case "WaitGroup":
+		state, ok := loadAtomic(v.FieldByName("state"))
+		if !ok {
+			return false
+		}
+		fields = []string{
+			"counter", strconv.Itoa(int(int32(state >> 32))),
+			"waiters", strconv.Itoa(int(state & 0x7fffffff)),
+		}
+	case "Mutex":
+		state := v.FieldByName("state")
+		if mu := v.FieldByName("mu"); mu.IsValid() && mu.Kind() == reflect.Struct {
+			state = mu.FieldByName("state")
+		}
+		n, ok := loadAtomic(state)
+		if !ok {
+			return false
+		}
+		fields = []string{"locked", strconv.FormatBool(n&1 != 0)}
+	default:
+		return false
+	}
+
+	separator, colon := ", ", ": "
+	if s.config.Compact {
+		separator, colon = ",", ":"
+	}
+	s.dumpType(v)
+	s.writeString("{")
+	for i := 0; i < len(fields); i += 2 {
+		if i > 0 {
+			s.writeString(separator)
+		}
+		s.writeString(fields[i] + colon + fields[i+1])
+	}
+	s.writeString("}")
+	return true
+}
+
+// loadAtomic atomically loads an addressable integer field, or a field of a sync/atomic type with
+// a Load method, returning false for fields of other types.
+func loadAtomic(f reflect.Value) (uint64, bool) {
+	if !f.IsValid() || !f.CanAddr() {
+		return 0, false
+	}
+	addr := unsafe.Pointer(f.UnsafeAddr())
+	switch f.Kind() {
+	case reflect.Int32:
+		return uint64(uint32(atomic.LoadInt32((*int32)(addr)))), true
+	case reflect.Uint32:
+		return uint64(atomic.LoadUint32((*uint32)(addr))), true
+	case reflect.Uint64:
+		return atomic.LoadUint64((*uint64)(addr)), true
+	case reflect.Struct:
+		if f.Type().PkgPath() != "sync/atomic" {
+			return 0, false
+		}
+		load := reflect.NewAt(f.Type(), addr).MethodByName("Load")
+		if !load.IsValid() || load.Type().NumIn() != 0 || load.Type().NumOut() != 1 {
+			return 0, false
+		}
+		switch result := load.Call(nil)[0]; result.Kind() {
+		case reflect.Bool:
+			if result.Bool() {
+				return 1, true
+			}
+			return 0, true
+		case reflect.Int32, reflect.Int64:
+			return uint64(result.Int()), true
+		case reflect.Uint32, reflect.Uint64:
+			return result.Uint(), true
+		}
+	}
+	return 0, false
+}
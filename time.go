@@ -0,0 +1,100 @@
+package litter
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// dumpTime dumps a time.Time without descending into its unexported internals, which are
+// meaningless to the reader. The time itself is shown in a comment, in TimeLocation if set, followed
+// by the zone name unless it is UTC or only repeats the offset. TimeFormat can dump times as Unix
+// timestamps or formatted strings instead. With StrictGo, times are dumped as calls to time.Date.
+func dumpTime(s *dumpState, value reflect.Value) bool {
+	v := deInterface(value)
+	if v.Type() != timeType {
+		return false
+	}
+	if !v.CanInterface() && (s.config.TimeFormat != "" || s.config.StrictGo) {
+		v = exposeUnexported(v)
+	}
+	if !v.CanInterface() {
+		if s.config.StrictGo {
+			return false
+		}
+		s.dumpType(v)
+		s.writeString("{}")
+		return true
+	}
+	t := v.Interface().(time.Time)
+	if s.config.TimeLocation != nil {
+		t = t.In(s.config.TimeLocation)
+	}
+	switch {
+	case s.config.StrictGo && !t.IsZero():
+		s.dumpTimeDate(t)
+		return true
+	case s.config.TimeFormat == "" || t.IsZero():
+		s.dumpType(v)
+		s.writeString("{}")
+	case s.config.TimeFormat == "unix":
+		s.writeString(strconv.FormatInt(t.Unix(), 10))
+	case s.config.TimeFormat == "unixmilli":
+		s.writeString(strconv.FormatInt(t.Unix()*1000+int64(t.Nanosecond())/1e6, 10))
+	default:
+		s.writeString(strconv.Quote(t.Format(s.config.TimeFormat)))
+		return true
+	}
+	s.inlineComment(timeText(t))
+	return true
+}
+
+// timeLiteralType returns the type of the literal TimeFormat dumps the time as, int64 or string, or
+// "" if the value is not dumped as a literal, so that pointers to it can be dumped as valid Go.
+func (s *dumpState) timeLiteralType(v reflect.Value) string {
+	if v.Type() != timeType || s.config.TimeFormat == "" || s.config.StrictGo || s.config.DisableDefaultDumpers {
+		return ""
+	}
+	if exposeUnexported(v).Interface().(time.Time).IsZero() {
+		return ""
+	}
+	if s.config.TimeFormat == "unix" || s.config.TimeFormat == "unixmilli" {
+		return "int64"
+	}
+	return "string"
+}
+
+// dumpTimeDate dumps the time as a call to time.Date, like time.Date(2024, time.January, 2, 3, 4, 5,
+// 0, time.UTC). Locations other than UTC and Local are dumped as fixed zones with the same name and
+// offset.
+func (s *dumpState) dumpTimeDate(t time.Time) {
+	location := s.qualifiedName("time.UTC")
+	switch t.Location() {
+	case time.UTC:
+	case time.Local:
+		location = s.qualifiedName("time.Local")
+	default:
+		name, offset := t.Zone()
+		location = s.qualifiedName("time.FixedZone") + "(" + strconv.Quote(name) + s.compactable(", ") +
+			strconv.Itoa(offset) + ")"
+	}
+	args := []string{
+		strconv.Itoa(t.Year()), s.qualifiedName("time." + t.Month().String()), strconv.Itoa(t.Day()),
+		strconv.Itoa(t.Hour()), strconv.Itoa(t.Minute()), strconv.Itoa(t.Second()),
+		strconv.Itoa(t.Nanosecond()), location,
+	}
+	s.writeString(s.qualifiedName("time.Date") + "(" + strings.Join(args, s.compactable(", ")) + ")")
+}
+
+// timeText returns the time in RFC 3339 format, followed by the zone name unless it is UTC or only
+// repeats the offset.
+func timeText(t time.Time) string {
+	text := t.Format(time.RFC3339Nano)
+	if zone, _ := t.Zone(); zone != "" && zone != "UTC" && zone[0] != '+' && zone[0] != '-' {
+		text += " " + zone
+	}
+	return text
+}
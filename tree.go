@@ -0,0 +1,159 @@
+package litter
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strconv"
+)
+
+// NodeKind is the kind of a Node.
+type NodeKind int
+
+const (
+	// ScalarNode is a value dumped as a single literal, such as a number, string, function, nil, or
+	// a value dumped by a custom dumper.
+	ScalarNode NodeKind = iota
+
+	// StructNode is a struct, with a child for each dumped field.
+	StructNode
+
+	// SliceNode is a slice or array, with a child for each element.
+	SliceNode
+
+	// MapNode is a map, with a child for each dumped entry.
+	MapNode
+
+	// PointerRefNode is a reference to a pointer dumped elsewhere in the tree, whose label is the
+	// value of the node.
+	PointerRefNode
+)
+
+// Node is a node in the tree of a dumped value returned by SdumpTree. Pointers are followed, so the
+// node of a pointer describes the value it points to.
+type Node struct {
+	Kind NodeKind
+
+	// Name is the name of the node in its parent: the field name in structs, the index in slices,
+	// and the dumped key in maps, like "key" with quotes for string keys.
+	Name string
+
+	// Type is the dumped type name of the value.
+	Type string
+
+	// Value is the dumped value of scalars, and the label of the referenced pointer for pointer
+	// references.
+	Value string
+
+	// Label is the label of the pointer to the value, like p0, if the pointer is referenced
+	// elsewhere.
+	Label string
+
+	Children []*Node
+}
+
+// Child returns the child of the node with the given name, or nil if there is none.
+func (n *Node) Child(name string) *Node {
+	if n == nil {
+		return nil
+	}
+	for _, child := range n.Children {
+		if child.Name == name {
+			return child
+		}
+	}
+	return nil
+}
+
+// SdumpTree dumps a value to a tree of nodes mirroring what Sdump would print, which allows tests to
+// assert on parts of the dump without comparing strings.
+func (o Options) SdumpTree(value interface{}) *Node {
+	v := reflect.ValueOf(value)
+	return newDumpState(v, &o, io.Discard).treeNode(v)
+}
+
+func (s *dumpState) treeNode(value reflect.Value) *Node {
+	s.recursion++
+	defer func() { s.recursion-- }()
+	v := deInterface(value)
+	label := ""
+	for isPointerValue(v) && !v.IsNil() {
+		if text, ok := s.leafText(v); ok {
+			return &Node{Kind: ScalarNode, Type: s.typeName(v.Type()), Value: text, Label: label}
+		}
+		if ptr, firstVisit := s.pointerFor(v); ptr != nil {
+			if !firstVisit {
+				t := v.Type()
+				for t.Kind() == reflect.Ptr {
+					t = t.Elem()
+				}
+				return &Node{
+					Kind:  PointerRefNode,
+					Type:  s.typeName(t),
+					Value: s.pointerLabel(ptr),
+				}
+			}
+			label = s.pointerLabel(ptr)
+		}
+		if v.Kind() != reflect.Ptr {
+			break
+		}
+		v = deInterface(v.Elem())
+	}
+	if !v.IsValid() || v.Kind() == reflect.Interface || isPointerValue(v) && v.IsNil() {
+		return &Node{Kind: ScalarNode, Value: "nil"}
+	}
+
+	node := &Node{
+		Type:  s.typeName(v.Type()),
+		Label: label,
+	}
+	if text, ok := s.leafText(v); ok {
+		node.Value = text
+		return node
+	}
+	w := s.w
+	buf := new(bytes.Buffer)
+	s.w = buf
+	handled := s.dumpWithDefaultDumpers(v)
+	s.w = w
+	if handled {
+		node.Value = buf.String()
+		return node
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		node.Kind = StructNode
+		vt := v.Type()
+		for _, i := range s.visibleFields(v) {
+			s.inField(v, i, func(field reflect.Value) {
+				child := s.treeNode(field)
+				child.Name = vt.Field(i).Name
+				node.Children = append(node.Children, child)
+			})
+		}
+	case reflect.Slice, reflect.Array:
+		node.Kind = SliceNode
+		for i := 0; i < v.Len(); i++ {
+			s.pushIndex(i)
+			child := s.treeNode(v.Index(i))
+			s.popPath()
+			child.Name = strconv.Itoa(i)
+			node.Children = append(node.Children, child)
+		}
+	case reflect.Map:
+		node.Kind = MapNode
+		keys, _ := s.dumpedMapKeys(v)
+		for _, key := range keys {
+			s.pushKey(key)
+			child := s.treeNode(v.MapIndex(key))
+			s.popPath()
+			child.Name = s.keyString(key)
+			node.Children = append(node.Children, child)
+		}
+	default:
+		node.Value = s.compactString(v)
+	}
+	return node
+}
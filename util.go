@@ -2,6 +2,7 @@ package litter
 
 import (
 	"reflect"
+	"unsafe"
 )
 
 // deInterface returns values inside of non-nil interfaces when possible.
@@ -14,6 +15,16 @@ func deInterface(v reflect.Value) reflect.Value {
 	return v
 }
 
+// derefPointers returns the value that v points to, following pointers until a non-pointer or nil
+// pointer is reached.
+func derefPointers(v reflect.Value) reflect.Value {
+	v = deInterface(v)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = deInterface(v.Elem())
+	}
+	return v
+}
+
 func isPointerValue(v reflect.Value) bool {
 	switch v.Kind() {
 	case reflect.Chan, reflect.Func, reflect.Map, reflect.Ptr, reflect.Slice, reflect.UnsafePointer:
@@ -26,3 +37,71 @@ func isZeroValue(v reflect.Value) bool {
 	return (isPointerValue(v) && v.IsNil()) ||
 		(v.IsValid() && v.CanInterface() && reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface()))
 }
+
+func isCompositeKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.Struct:
+		return true
+	}
+	return false
+}
+
+// isScalarKind returns true for booleans, numbers and strings.
+func isScalarKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return true
+	}
+	return false
+}
+
+// isEmptyValue returns true for nil or empty slices and maps.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Map, reflect.Slice:
+		return v.Len() == 0
+	}
+	return false
+}
+
+// numericValue returns the value of integers and floats as a float64.
+func numericValue(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+// exposeUnexported returns v such that it can be used with Interface and Call even if it was
+// obtained through private struct fields. Structs are copied so that their fields are addressable.
+func exposeUnexported(v reflect.Value) reflect.Value {
+	if v.Kind() == reflect.Struct && !v.CanAddr() && v.CanInterface() {
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		return c
+	}
+	if v.CanInterface() || !v.CanAddr() {
+		return v
+	}
+	return reflect.NewAt(v.Type(), unsafe.Pointer(v.UnsafeAddr())).Elem()
+}
+
+// addressable returns an addressable copy of a struct or array, whose fields and elements are then
+// addressable too, which lets exposeUnexported access the values of unexported fields. Other values
+// are returned as is.
+func addressable(v reflect.Value) reflect.Value {
+	if v.Kind() != reflect.Struct && v.Kind() != reflect.Array {
+		return v
+	}
+	c := reflect.New(v.Type()).Elem()
+	c.Set(v)
+	return c
+}
@@ -0,0 +1,62 @@
+package litter
+
+import "reflect"
+
+// Version is the version of litter.
+const Version = "1.6.0"
+
+// features lists the exported identifiers of the package, other than the fields and methods of
+// Options, which are found by reflection. TestSupportsFeature checks that it is complete.
+var features = map[string]bool{
+	"ConditionalDumper": true,
+	"Config":            true,
+	"D":                 true,
+	"Debug":             true,
+	"Dump":              true,
+	"DumpContext":       true,
+	"Dumper":            true,
+	"DumpsEqual":        true,
+	"Fdump":             true,
+	"FdumpWith":         true,
+	"Format":            true,
+	"FormatFlatPaths":   true,
+	"FormatGo":          true,
+	"FormatLogfmt":      true,
+	"FormatRepr":        true,
+	"FormatYAML":        true,
+	"IndentedDumper":    true,
+	"KeyOrderer":        true,
+	"MapNode":           true,
+	"NewStateful":       true,
+	"Node":              true,
+	"NodeKind":          true,
+	"NormalizeDump":     true,
+	"Options":           true,
+	"PointerRefNode":    true,
+	"PointerStat":       true,
+	"Region":            true,
+	"ReusedPointers":    true,
+	"ScalarNode":        true,
+	"Sdump":             true,
+	"SdumpWith":         true,
+	"SliceNode":         true,
+	"Stateful":          true,
+	"StructNode":        true,
+	"SupportsFeature":   true,
+	"Version":           true,
+}
+
+// SupportsFeature returns true if this version of litter supports the named feature. Features are
+// named after the fields and methods of Options, like "MaxDepth", and after other exported
+// identifiers, like "FormatYAML" or "ReusedPointers". This allows code depending on litter to use
+// newer features only when available.
+func SupportsFeature(name string) bool {
+	optionsType := reflect.TypeOf(Options{})
+	if _, ok := optionsType.FieldByName(name); ok {
+		return true
+	}
+	if _, ok := optionsType.MethodByName(name); ok {
+		return true
+	}
+	return features[name]
+}
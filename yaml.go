@@ -0,0 +1,213 @@
+package litter
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The position a YAML node is written in.
+const (
+	yamlRoot = iota
+	yamlMappingValue
+	yamlSequenceItem
+)
+
+// dumpYAML dumps the value in an indentation-based layout resembling YAML. Reused pointers are
+// written as anchors (&p0) on first occurrence and as aliases (*p0) afterwards.
+func (s *dumpState) dumpYAML(value reflect.Value) {
+	if s.config.Compact {
+		opts := *s.config
+		opts.Compact = false
+		s.config = &opts
+	}
+	w := s.w
+	buf := new(bytes.Buffer)
+	s.w = buf
+	s.yamlNode(value, yamlRoot, "")
+	s.w = w
+	s.write(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+}
+
+// yamlNode writes a node following a "key:" or "-" already written by the caller, or at the start
+// of the output for the root node. The tag, if not empty, is written with the anchor.
+func (s *dumpState) yamlNode(value reflect.Value, position int, tag string) {
+	s.recursion++
+	defer func() { s.recursion-- }()
+	v := deInterface(value)
+	anchor := ""
+	for {
+		if text, ok := s.leafText(v); ok {
+			s.yamlScalar(strings.TrimPrefix(anchor+" "+strconv.Quote(text), " "), position)
+			return
+		}
+		if isPointerValue(v) && v.IsNil() || v.Kind() == reflect.Interface || !v.IsValid() {
+			s.yamlScalar("null", position)
+			return
+		}
+		if !isPointerValue(v) {
+			break
+		}
+		if ptr, firstVisit := s.pointerFor(v); ptr != nil {
+			if !firstVisit {
+				s.yamlScalar("*"+s.pointerLabel(ptr), position)
+				return
+			}
+			if anchor == "" {
+				anchor = "&" + s.pointerLabel(ptr)
+			}
+		}
+		if v.Kind() != reflect.Ptr {
+			break
+		}
+		v = deInterface(v.Elem())
+	}
+	if tag != "" {
+		anchor = strings.TrimPrefix(anchor+" "+tag, " ")
+	}
+
+	if text, ok := s.yamlScalarText(v); ok {
+		if anchor != "" {
+			text = anchor + " " + text
+		}
+		s.yamlScalar(text, position)
+		return
+	}
+
+	switch position {
+	case yamlMappingValue:
+		if anchor != "" {
+			s.writeString(" " + anchor)
+		}
+		s.writeString("\n")
+		s.depth++
+	case yamlSequenceItem:
+		if anchor != "" {
+			s.writeString(" " + anchor + "\n")
+		} else {
+			s.writeString(" ")
+			s.skipIndent = true
+		}
+		s.depth++
+	default:
+		if anchor != "" {
+			s.writeString(anchor + "\n")
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		s.yamlStruct(v)
+	case reflect.Map:
+		s.yamlMap(v)
+	default:
+		s.yamlSequence(v)
+	}
+
+	if position != yamlRoot {
+		s.depth--
+	}
+}
+
+func (s *dumpState) yamlScalar(text string, position int) {
+	if position == yamlRoot {
+		s.writeString(text)
+		return
+	}
+	s.writeString(" " + text + "\n")
+}
+
+// yamlScalarText returns the text of a value that is written on a single line, including empty
+// collections.
+func (s *dumpState) yamlScalarText(v reflect.Value) (string, bool) {
+	switch v.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return s.compactString(v), true
+	case reflect.String:
+		return strconv.Quote(v.String()), true
+	case reflect.Struct:
+		if v.Type() == timeType && v.CanInterface() {
+			return strconv.Quote(v.Interface().(time.Time).Format(time.RFC3339Nano)), true
+		}
+		if len(s.visibleFields(v)) == 0 {
+			return "{}", true
+		}
+		return "", false
+	case reflect.Map:
+		if len(s.visibleMapKeys(v)) == 0 {
+			return "{}", true
+		}
+		return "", false
+	case reflect.Slice, reflect.Array:
+		if v.Len() == 0 {
+			return "[]", true
+		}
+		return "", false
+	}
+	return strconv.Quote(s.compactString(v)), true
+}
+
+func (s *dumpState) yamlStruct(v reflect.Value) {
+	vt := v.Type()
+	for _, i := range s.visibleFields(v) {
+		name := vt.Field(i).Name
+		s.indent()
+		s.writeString(name + ":")
+		s.inField(v, i, func(field reflect.Value) {
+			s.yamlNode(field, yamlMappingValue, s.yamlInterfaceTag(field))
+		})
+	}
+}
+
+func (s *dumpState) yamlMap(v reflect.Value) {
+	keys, omitted := s.dumpedMapKeys(v)
+	for _, key := range keys {
+		s.mapKey = key
+		text, ok := s.leafText(deInterface(key))
+		if ok {
+			text = strconv.Quote(text)
+		} else if text, ok = s.yamlScalarText(deInterface(key)); !ok {
+			text = strconv.Quote(s.compactString(key))
+		}
+		s.indent()
+		s.writeString(text + ":")
+		s.pushKey(key)
+		s.yamlNode(v.MapIndex(key), yamlMappingValue, "")
+		s.popPath()
+	}
+	if omitted > 0 {
+		s.indent()
+		s.writeString(fmt.Sprintf("# ... %d more\n", omitted))
+	}
+}
+
+func (s *dumpState) yamlSequence(v reflect.Value) {
+	for i := 0; i < v.Len(); i++ {
+		s.indent()
+		s.writeString("-")
+		s.pushIndex(i)
+		s.yamlNode(v.Index(i), yamlSequenceItem, "")
+		s.popPath()
+	}
+}
+
+// yamlInterfaceTag returns the tag naming the dynamic type of an interface field holding a struct or
+// a pointer to one, if TagInterfaceFields is set.
+func (s *dumpState) yamlInterfaceTag(v reflect.Value) string {
+	if !s.config.TagInterfaceFields || v.Kind() != reflect.Interface || v.IsNil() {
+		return ""
+	}
+	t := v.Elem().Type()
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t.Name() == "" {
+		return ""
+	}
+	return "!" + s.typeName(t)
+}